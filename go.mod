@@ -12,7 +12,7 @@ go 1.21.0
 require (
 	github.com/go-redis/redis/v7 v7.4.1
 	github.com/google/uuid v1.5.0
-	golang.org/x/net v0.20.0 // indirect
+	golang.org/x/net v0.20.0
 	golang.org/x/text v0.14.0 // indirect
 )
 
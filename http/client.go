@@ -2,15 +2,77 @@ package http
 
 import (
 	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/textproto"
+	neturl "net/url"
 	"strings"
+	"time"
 
 	"github.com/curol/network/url"
+	"golang.org/x/net/http/httpproxy"
 )
 
+// Client is an HTTP client.
+//
+// A Client created with [NewClient] sends the request it was created with when [Client.Do] is called.
+// Any Client, including the zero value, can send requests with [Client.Send], [Client.Get],
+// [Client.Post], and [Client.Head].
 type Client struct {
+	// CheckRedirect specifies the policy for handling redirects.
+	// If CheckRedirect is not nil, the client calls it before
+	// following an HTTP redirect. The arguments req and via are
+	// the upcoming request and the requests made already, oldest
+	// first. If CheckRedirect returns an error, the Client's Send
+	// method returns both the previous Response (with its Body
+	// closed) and CheckRedirect's error instead of issuing the
+	// Request req. As a special case, if CheckRedirect returns
+	// ErrUseLastResponse, then the most recent response is returned
+	// with its body unclosed, along with a nil error.
+	//
+	// If CheckRedirect is nil, the Client uses its default policy,
+	// which is to stop after 10 consecutive requests.
+	CheckRedirect func(req *Request, via []*Request) error
+
+	// MaxResponseBytes limits the number of bytes of the response body
+	// the client reads, after any decompression. Reading beyond the limit
+	// returns a [*ResponseTooLargeError].
+	// If zero, no limit is applied.
+	MaxResponseBytes int64
+
+	// DisableCompression, if true, prevents the Client from requesting
+	// compression with an "Accept-Encoding: gzip, deflate" request header
+	// when the Request doesn't have an Accept-Encoding. If the Client requested
+	// compression, a response body with a "Content-Encoding" of gzip or
+	// deflate is transparently decompressed, and its Content-Encoding and
+	// Content-Length headers are removed, since they describe the
+	// compressed body. If the user explicitly requested compression,
+	// the body is not decompressed.
+	DisableCompression bool
+
+	// Proxy specifies a function to return a proxy for a given
+	// Request. If the function returns a non-nil error, the
+	// request is aborted with the provided error.
+	//
+	// Only "http" proxies are supported. Requests for "http" URLs are
+	// sent to the proxy with an absolute URL as the request-target, and
+	// requests for "https" URLs are tunneled through the proxy with CONNECT.
+	//
+	// If Proxy is nil or returns a nil *url.URL, no proxy is used.
+	Proxy func(*Request) (*url.URL, error)
+
+	// ExpectContinueTimeout, if non-zero, makes the Client send requests
+	// with a body with an "Expect: 100-continue" header and hold back the
+	// body until the server replies "100 Continue". If the server replies
+	// with a final status instead, such as 417 Expectation Failed, the body
+	// isn't sent and that response is returned. If the server doesn't
+	// reply within the timeout, the body is sent anyway.
+	ExpectContinueTimeout time.Duration
+
 	network  string
 	protocol string
 	method   string
@@ -19,16 +81,51 @@ type Client struct {
 	header   map[string][]string
 	body     io.Reader
 
-	conn net.Conn  // connection to server
-	req  *Request  // request
-	res  *Response // response
+	req *Request  // request
+	res *Response // response
+}
+
+// DefaultClient is the default [Client] and is used by [Get], [Head], and [Post].
+// It uses the proxy set in the environment, see [ProxyFromEnvironment].
+var DefaultClient = &Client{Proxy: ProxyFromEnvironment}
+
+// ProxyFromEnvironment returns the URL of the proxy to use for a
+// given request, as indicated by the environment variables
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY (or the lowercase versions
+// thereof). Requests use the proxy from the environment variable
+// matching their scheme, unless excluded by NO_PROXY.
+//
+// The environment values may be either a complete URL or a
+// "host[:port]", in which case the "http" scheme is assumed.
+// An error is returned if the value is a different form.
+//
+// NO_PROXY is a comma-separated list of host names, which also match
+// their subdomains, domain suffixes such as ".example.com", IP addresses,
+// and CIDR ranges such as "10.0.0.0/8". A single asterisk (*) disables
+// the proxy for all requests.
+//
+// A nil URL and nil error are returned if no proxy is defined in the
+// environment, or a proxy should not be used for the given request,
+// as defined by NO_PROXY. Requests to "localhost" and loopback addresses
+// are never proxied. The environment is read on each call.
+func ProxyFromEnvironment(req *Request) (*url.URL, error) {
+	u, err := neturl.Parse(req.URL.String())
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := httpproxy.FromEnvironment().ProxyFunc()(u)
+	if proxy == nil || err != nil {
+		return nil, err
+	}
+	return url.Parse(proxy.String())
 }
 
 func NewClient(method string, address string, header map[string][]string, body io.Reader) *Client {
 	// Set request line
 	method = strings.ToUpper(strings.TrimSpace(method))
 	address = strings.TrimSpace(address)
-	u, err := url.Parse(address)
+	rawurl, _ := addSchemeIfMissing(address)
+	u, err := parseURL(rawurl)
 	if err != nil {
 		panic(err)
 	}
@@ -45,104 +142,510 @@ func NewClient(method string, address string, header map[string][]string, body i
 	return client
 }
 
-func Get(address string, header map[string][]string, body io.Reader) []byte {
-	c := NewClient("GET", address, header, body)
-	defer c.Clean()
-	// Create request
-	req, err := NewRequest(c.method, c.address, c.header, body)
+// Get issues a GET to the specified URL with the [DefaultClient].
+//
+// The caller should close resp.Body when done reading from it.
+func Get(url string) (resp *Response, err error) {
+	return DefaultClient.Get(url)
+}
+
+// Head issues a HEAD to the specified URL with the [DefaultClient].
+func Head(url string) (resp *Response, err error) {
+	return DefaultClient.Head(url)
+}
+
+// Post issues a POST to the specified URL with the [DefaultClient].
+//
+// The caller should close resp.Body when done reading from it.
+func Post(url, contentType string, body io.Reader) (resp *Response, err error) {
+	return DefaultClient.Post(url, contentType, body)
+}
+
+// Get issues a GET to the specified URL.
+//
+// The caller should close resp.Body when done reading from it.
+func (c *Client) Get(url string) (resp *Response, err error) {
+	req, err := NewRequest("GET", url, nil, nil)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	// Write request
-	w := bufio.NewWriter(c.conn)
-	err = req.Write(w)
+	return c.Send(req)
+}
+
+// Head issues a HEAD to the specified URL.
+func (c *Client) Head(url string) (resp *Response, err error) {
+	req, err := NewRequest("HEAD", url, nil, nil)
 	if err != nil {
-		if err != io.EOF {
-			panic(err)
-		}
+		return nil, err
 	}
-	// Read response
-	r := bufio.NewReader(c.conn)
-	if r == nil {
-		return nil
+	return c.Send(req)
+}
+
+// Post issues a POST to the specified URL.
+//
+// The caller should close resp.Body when done reading from it.
+func (c *Client) Post(url, contentType string, body io.Reader) (resp *Response, err error) {
+	req, err := NewRequest("POST", url, nil, body)
+	if err != nil {
+		return nil, err
 	}
-	fl, _ := r.ReadString('\n')
-	// Finish
-	return []byte(fl)
+	req.Header.Set("Content-Type", contentType)
+	return c.Send(req)
 }
 
 func (c *Client) Parse(r *bufio.Reader) {
 
 }
 
+// Do sends the request the client was created with to the server and returns its response.
+// The connection to the server is closed when the response body is closed,
+// or immediately if the response has no body.
 func (c *Client) Do() *Response {
-	// 1. Connect
-	c.dial()
-
-	// 2. Clean up
-	defer c.Clean()
-
-	// 3. Write request
-	req, err := NewRequest(c.method, c.address, c.header, io.NopCloser(c.body))
+	// 1. Create request
+	req, err := NewRequest(c.method, c.address, c.header, c.body)
 	if err != nil {
 		panic(err)
 	}
-	err = req.Write(c.conn)
-	if err != nil {
-		if err != io.EOF {
-			panic(err)
-		}
-	}
 	c.req = req
 
-	// 4. Read response
-	// io.Copy(c.conn, c.res.Body)
-	resp, err := ReadResponse(c.conn)
+	// 2. Send request
+	resp, err := c.Send(req)
 	if err != nil {
 		if err != io.EOF {
 			panic(err)
 		}
+		return resp
 	}
 	c.res = resp
 	return resp
 }
 
-// Connect connects to the server.
-func (c *Client) dial() {
-	conn, err := net.Dial(c.network, c.address) // start connection
+// ErrUseLastResponse can be returned by Client.CheckRedirect hooks to
+// control how redirects are processed. If returned, the next request
+// is not sent and the most recent response is returned with its body
+// unclosed.
+var ErrUseLastResponse = errors.New("net/http: use last response")
+
+// Send sends the request to the server and returns its response,
+// following redirects as configured by the client's CheckRedirect.
+//
+// A relative Location in a redirect, such as "/next" or "//host/next",
+// is resolved against the URL of the request that was redirected.
+//
+// The response body is always non-nil. The connection to the server is closed
+// when the response body is closed, or immediately if the response has no body.
+func (c *Client) Send(req *Request) (*Response, error) {
+	var via []*Request
+	for {
+		resp, err := c.send(req)
+		if err != nil {
+			return nil, err
+		}
+
+		// 1. Check if the response is a redirect to follow
+		method, includeBody, ok := redirectBehavior(req.Method, resp)
+		if !ok {
+			return resp, nil
+		}
+		loc := resp.Header.Get("Location")
+		if loc == "" {
+			return resp, nil
+		}
+		if includeBody && req.GetBody == nil && req.Body != nil && req.Body != NoBody {
+			// The body was consumed and can't be replayed, so return the redirect response.
+			return resp, nil
+		}
+		u, err := req.URL.Parse(loc) // resolve relative to the request URL
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to parse Location header %q: %v", loc, err)
+		}
+
+		// 2. Create the redirected request
+		via = append(via, req)
+		next := &Request{
+			Method:     method,
+			Proto:      req.Proto,
+			ProtoMajor: req.ProtoMajor,
+			ProtoMinor: req.ProtoMinor,
+			URL:        u,
+			Host:       u.Host,
+			Header:     req.Header.Clone(),
+			ctx:        req.ctx,
+		}
+		for k := range next.Header {
+			if !shouldCopyHeaderOnRedirect(k, via[0].URL, u) {
+				// Credentials aren't sent to a host other than the one
+				// they were given for.
+				next.Header.Del(k)
+			}
+		}
+		if includeBody && req.GetBody != nil {
+			next.Body, err = req.GetBody()
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			next.GetBody = req.GetBody
+			next.ContentLength = req.ContentLength
+		} else {
+			next.Header.Del("Content-Type")
+			next.Header.Del("Content-Length")
+		}
+
+		// 3. Check the redirect policy
+		err = c.checkRedirect(next, via)
+		if err == ErrUseLastResponse {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if err != nil {
+			return resp, err
+		}
+		req = next
+	}
+}
+
+// checkRedirect calls either the user's configured CheckRedirect
+// function, or the default.
+func (c *Client) checkRedirect(req *Request, via []*Request) error {
+	fn := c.CheckRedirect
+	if fn == nil {
+		fn = defaultCheckRedirect
+	}
+	return fn(req, via)
+}
+
+func defaultCheckRedirect(req *Request, via []*Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// shouldCopyHeaderOnRedirect reports whether the header headerKey of the
+// initial request is forwarded to a redirect to dest. Sensitive headers are
+// only forwarded to the initial host or a subdomain of it, so a redirect to
+// another host, e.g. from "foo.com" to "bar.com", drops them.
+func shouldCopyHeaderOnRedirect(headerKey string, initial, dest *url.URL) bool {
+	switch textproto.CanonicalMIMEHeaderKey(headerKey) {
+	case "Authorization", "Www-Authenticate", "Cookie", "Cookie2", "Proxy-Authorization":
+		ihost := strings.ToLower(initial.Hostname())
+		dhost := strings.ToLower(dest.Hostname())
+		return isDomainOrSubdomain(dhost, ihost)
+	}
+	return true
+}
+
+// isDomainOrSubdomain reports whether sub is a subdomain (or exact
+// match) of the parent domain.
+//
+// Both domains must already be in canonical form.
+func isDomainOrSubdomain(sub, parent string) bool {
+	if sub == parent {
+		return true
+	}
+	// If sub contains a :, it's probably an IPv6 address (and is
+	// definitely not a hostname). Don't check the suffix in this case.
+	if strings.ContainsAny(sub, ":%") {
+		return false
+	}
+	// If sub is "foo.example.com" and parent is "example.com",
+	// that means sub must end in "."+parent.
+	// Do it without allocating.
+	if !strings.HasSuffix(sub, parent) {
+		return false
+	}
+	return sub[len(sub)-len(parent)-1] == '.'
+}
+
+// redirectBehavior describes what should happen when the
+// client encounters a 3xx status code from the server.
+func redirectBehavior(reqMethod string, resp *Response) (redirectMethod string, includeBody, shouldRedirect bool) {
+	switch resp.StatusCode {
+	case 301, 302, 303:
+		redirectMethod = reqMethod
+		shouldRedirect = true
+		includeBody = false
+
+		// RFC 2616 allowed automatic redirection only with GET and
+		// HEAD requests. RFC 7231 lifts this restriction, but we still
+		// restrict other methods to GET to maintain compatibility.
+		// See Issue 18570.
+		if reqMethod != "GET" && reqMethod != "HEAD" {
+			redirectMethod = "GET"
+		}
+	case 307, 308:
+		redirectMethod = reqMethod
+		shouldRedirect = true
+		includeBody = true
+	}
+	return redirectMethod, includeBody, shouldRedirect
+}
+
+// send sends a single request to the server and returns its response.
+func (c *Client) send(req *Request) (*Response, error) {
+	if req.URL == nil {
+		return nil, errors.New("http: nil Request.URL")
+	}
+	if req.URL.Scheme != "" && req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("http: unsupported protocol scheme %q", req.URL.Scheme)
+	}
+
+	// 1. Connect, through the proxy if there is one
+	var proxyURL *url.URL
+	if c.Proxy != nil {
+		var err error
+		proxyURL, err = c.Proxy(req)
+		if err != nil {
+			return nil, err
+		}
+		if proxyURL != nil && proxyURL.Scheme != "" && proxyURL.Scheme != "http" {
+			return nil, fmt.Errorf("http: unsupported proxy scheme %q", proxyURL.Scheme)
+		}
+	}
+	conn, err := c.dial(req.URL, proxyURL)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	// 2. Write request, asking for a compressed response unless the
+	// caller chose an encoding. A range of a compressed body can't be
+	// decompressed, so compression isn't asked for with a Range.
+	wreq := req
+	requestedCompression := false
+	if !c.DisableCompression && req.Method != "HEAD" && req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		requestedCompression = true
+		wreq = new(Request)
+		*wreq = *req
+		wreq.Header = req.Header.Clone()
+		if wreq.Header == nil {
+			wreq.Header = NewHeader()
+		}
+		wreq.Header.Set("Accept-Encoding", "gzip, deflate")
 	}
-	c.conn = conn
+	// With an expectation, only the head is written here, and the body
+	// waits for the server's interim response.
+	expectContinue := c.ExpectContinueTimeout > 0 && req.Body != nil && req.Body != NoBody && req.ContentLength > 0
+	if expectContinue {
+		if wreq == req {
+			wreq = new(Request)
+			*wreq = *req
+			wreq.Header = req.Header.Clone()
+			if wreq.Header == nil {
+				wreq.Header = NewHeader()
+			}
+		}
+		wreq.Header.Set("Expect", "100-continue")
+		wreq.Body = nil
+	}
+	if proxyURL != nil && req.URL.Scheme != "https" {
+		if auth := proxyAuth(proxyURL); auth != "" && wreq.Header.Get("Proxy-Authorization") == "" {
+			if wreq == req {
+				wreq = new(Request)
+				*wreq = *req
+				wreq.Header = req.Header.Clone()
+				if wreq.Header == nil {
+					wreq.Header = NewHeader()
+				}
+			}
+			wreq.Header.Set("Proxy-Authorization", auth)
+		}
+		err = wreq.WriteProxy(conn)
+	} else {
+		err = wreq.Write(conn)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// 3. Read response
+	br := bufio.NewReader(conn)
+	var resp *Response
+	if expectContinue {
+		resp, err = c.sendBodyOnContinue(conn, br, req)
+	}
+	if resp == nil && err == nil {
+		resp, err = ReadResponse(br)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Request = req
+
+	// 4. Clean up when the body is closed
+	if resp.Body == nil || req.Method == "HEAD" {
+		conn.Close()
+		resp.Body = NoBody
+		return resp, nil
+	}
+	if requestedCompression {
+		decompressBody(resp)
+	}
+	// The limit applies to the decompressed body, so a small compressed
+	// body can't inflate past it.
+	if c.MaxResponseBytes > 0 {
+		resp.Body = &maxResponseBody{MaxBytesReader(nil, resp.Body, c.MaxResponseBytes)}
+	}
+	resp.Body = &connBody{ReadCloser: resp.Body, conn: conn}
+	return resp, nil
 }
 
-// WriteRequest writes the request to the server.
-func (c *Client) write() error {
-	return c.req.Write(c.conn)
+// sendBodyOnContinue writes the body of req to conn once the server replies
+// "100 Continue" to its head, or once c.ExpectContinueTimeout passes without
+// a reply. If the server replies with a final status first, the body isn't
+// sent, and that response is returned. Otherwise, the returned response is nil
+// and the final response is read from br.
+func (c *Client) sendBodyOnContinue(conn net.Conn, br *bufio.Reader, req *Request) (*Response, error) {
+	conn.SetReadDeadline(time.Now().Add(c.ExpectContinueTimeout))
+	_, err := br.Peek(1) // wait for the first byte of a reply
+	conn.SetReadDeadline(time.Time{})
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		// No reply, so the server isn't waiting to be asked.
+	} else if err != nil {
+		return nil, err
+	} else {
+		for {
+			resp, err := ReadResponse(br)
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode == StatusContinue {
+				break
+			}
+			if resp.StatusCode >= 200 || resp.StatusCode == StatusSwitchingProtocols {
+				return resp, nil
+			}
+			// Skip other interim responses, e.g., 103 Early Hints.
+		}
+	}
+	_, err = io.CopyN(conn, req.Body, req.ContentLength)
+	return nil, err
 }
 
-// ReadResponse reads the response from the server.
-func (c *Client) read() (int64, error) {
-	return c.res.WriteTo(c.conn)
+// dial connects to the host of `u`, or to `proxy` if it isn't nil, using the
+// default port of the scheme if the host has no port.
+//
+// Connections for "https" URLs are secured with TLS, after opening a tunnel
+// to the host with CONNECT if connected to a proxy.
+func (c *Client) dial(u *url.URL, proxy *url.URL) (net.Conn, error) {
+	network := c.network
+	if network == "" {
+		network = "tcp"
+	}
+	addr := canonicalAddr(u)
+	if proxy != nil {
+		addr = canonicalAddr(proxy)
+	}
+	conn, err := net.Dial(network, addr) // start connection
+	if err != nil || u.Scheme != "https" {
+		return conn, err
+	}
+	if proxy != nil {
+		err = connectTunnel(conn, canonicalAddr(u), proxy)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+	err = tlsConn.Handshake()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
 }
 
-func (c *Client) readN(n int, conn net.Conn) (buf []byte, err error) {
-	b := make([]byte, n)
-	n, err = conn.Read(b)
+// connectTunnel asks the proxy connected to by conn to open a tunnel to addr, "host:port".
+func connectTunnel(conn net.Conn, addr string, proxy *url.URL) error {
+	req := &Request{
+		Method:     "CONNECT",
+		URL:        &url.URL{Opaque: addr},
+		Host:       addr,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     NewHeader(),
+	}
+	if auth := proxyAuth(proxy); auth != "" {
+		req.Header.Set("Proxy-Authorization", auth)
+	}
+	err := req.Write(conn)
 	if err != nil {
-		if err != io.EOF {
-			panic(err)
+		return err
+	}
+	// Nothing follows the response until the client starts the TLS handshake.
+	resp, err := ReadResponse(conn)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != StatusOK {
+		return fmt.Errorf("http: proxy refused CONNECT to %s: %d %s", addr, resp.StatusCode, resp.StatusText)
+	}
+	return nil
+}
+
+// proxyAuth returns the value of the Proxy-Authorization header for the
+// user info of proxy, or "" if it has none.
+func proxyAuth(proxy *url.URL) string {
+	u := proxy.User
+	if u == nil {
+		return ""
+	}
+	password, _ := u.Password()
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+password))
+}
+
+// canonicalAddr returns the "host:port" of u, using the default
+// port of the scheme of u if it has no port.
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
 		}
 	}
-	return b, nil
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// connBody is a response body that closes the connection it's read from when closed.
+type connBody struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (b *connBody) Close() error {
+	err := b.ReadCloser.Close()
+	if cerr := b.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// maxResponseBody reports the [*MaxBytesError] of a response body read
+// through [MaxBytesReader] as a [*ResponseTooLargeError].
+type maxResponseBody struct {
+	io.ReadCloser
+}
+
+func (b *maxResponseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if mbe, ok := err.(*MaxBytesError); ok {
+		err = &ResponseTooLargeError{mbe}
+	}
+	return n, err
 }
 
 // Clean closes the connection to the server and cleans up client.
 func (c *Client) Clean() error {
-	if c.conn == nil {
+	if c.res == nil || c.res.Body == nil {
 		return fmt.Errorf("Connection is nil")
 	}
-	return c.conn.Close()
+	return c.res.Body.Close()
 }
 
 //**********************************************************************************************************************
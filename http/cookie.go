@@ -9,14 +9,12 @@ import (
 	"fmt"
 	"log"
 	"net"
-	"net/http"
 	"net/textproto"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/curol/network/http/internal/ascii"
-	"github.com/curol/network/http/internal/timeformat"
 )
 
 // parseCookie parses a cookie from a cookie header.
@@ -53,7 +51,7 @@ func parseCookie(raw string) *Cookie {
 				cookie.Domain = value
 			case "Expires":
 				cookie.RawExpires = value
-				if t, err := time.Parse(time.RFC1123, value); err == nil {
+				if t, err := parseCookieExpires(value); err == nil {
 					cookie.Expires = t
 				}
 			case "Max-Age":
@@ -93,6 +91,15 @@ type Cookie struct {
 	Secure   bool
 	HttpOnly bool
 	SameSite SameSite
+
+	// Partitioned marks the cookie as partitioned by top-level site (CHIPS).
+	// See https://datatracker.ietf.org/doc/html/draft-cutler-httpbis-partitioned-cookies.
+	Partitioned bool
+
+	// Priority is the non-standard Chrome priority attribute. It is one of
+	// "Low", "Medium" or "High", or empty to omit the attribute.
+	Priority string
+
 	Raw      string
 	Unparsed []string // Raw text of unparsed attribute-value pairs
 }
@@ -184,6 +191,14 @@ func readSetCookies(h Header) []*Cookie {
 			case "httponly":
 				c.HttpOnly = true
 				continue
+			case "partitioned":
+				c.Partitioned = true
+				continue
+			case "priority":
+				if p := cookiePriority(val); p != "" {
+					c.Priority = p
+					continue
+				}
 			case "domain":
 				c.Domain = val
 				continue
@@ -199,13 +214,10 @@ func readSetCookies(h Header) []*Cookie {
 				continue
 			case "expires":
 				c.RawExpires = val
-				exptime, err := time.Parse(time.RFC1123, val)
+				exptime, err := parseCookieExpires(val)
 				if err != nil {
-					exptime, err = time.Parse("Mon, 02-Jan-2006 15:04:05 MST", val)
-					if err != nil {
-						c.Expires = time.Time{}
-						break
-					}
+					c.Expires = time.Time{}
+					break
 				}
 				c.Expires = exptime.UTC()
 				continue
@@ -215,11 +227,30 @@ func readSetCookies(h Header) []*Cookie {
 			}
 			c.Unparsed = append(c.Unparsed, parts[i])
 		}
+		if c.validPrefix() != nil {
+			// Like browsers, ignore prefixed cookies without the attributes
+			// their prefix requires.
+			continue
+		}
 		cookies = append(cookies, c)
 	}
 	return cookies
 }
 
+// parseCookieExpires parses the value of an Expires attribute. Besides the
+// formats of [ParseTime], it accepts [time.RFC1123] with any zone and the
+// "02-Jan-2006" date form some servers send.
+func parseCookieExpires(v string) (time.Time, error) {
+	if t, err := ParseTime(v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC1123, v)
+	if err != nil {
+		t, err = time.Parse("Mon, 02-Jan-2006 15:04:05 MST", v)
+	}
+	return t, err
+}
+
 func ReadSetCookies(h Header) []*Cookie {
 	return readSetCookies(h)
 }
@@ -232,7 +263,7 @@ func ReadCookies(h Header, filter string) []*Cookie {
 // returns the successfully parsed Cookies.
 //
 // if filter isn't empty, only cookies of that name are returned.
-func readCookies(h http.Header, filter string) []*Cookie {
+func readCookies(h Header, filter string) []*Cookie {
 	lines := h["Cookie"]
 	if len(lines) == 0 {
 		return []*Cookie{}
@@ -268,9 +299,13 @@ func readCookies(h http.Header, filter string) []*Cookie {
 }
 
 // SetCookie adds a Set-Cookie header to the provided [ResponseWriter]'s headers.
-// The provided cookie must have a valid Name. Invalid cookies may be
-// silently dropped.
+// The provided cookie must be valid as reported by [Cookie.Valid].
+// Invalid cookies are logged and dropped.
 func SetCookie(w ResponseWriter, cookie *Cookie) {
+	if err := cookie.Valid(); err != nil {
+		log.Printf("net/http: %v; dropping Set-Cookie", err)
+		return
+	}
 	if v := cookie.String(); v != "" {
 		w.Header().Add("Set-Cookie", v)
 	}
@@ -313,10 +348,10 @@ func (c *Cookie) String() string {
 			log.Printf("net/http: invalid Cookie.Domain %q; dropping domain attribute", c.Domain)
 		}
 	}
-	var buf [len(timeformat.TimeFormat)]byte
+	var buf [len(TimeFormat)]byte
 	if validCookieExpires(c.Expires) {
 		b.WriteString("; Expires=")
-		b.Write(c.Expires.UTC().AppendFormat(buf[:0], timeformat.TimeFormat))
+		b.Write(appendTime(buf[:0], c.Expires))
 	}
 	if c.MaxAge > 0 {
 		b.WriteString("; Max-Age=")
@@ -334,12 +369,25 @@ func (c *Cookie) String() string {
 	case SameSiteDefaultMode:
 		// Skip, default mode is obtained by not emitting the attribute.
 	case SameSiteNoneMode:
-		b.WriteString("; SameSite=None")
+		if c.Secure {
+			b.WriteString("; SameSite=None")
+		} else {
+			log.Printf("net/http: SameSite=None Cookie %q without Secure; dropping SameSite attribute", c.Name)
+		}
 	case SameSiteLaxMode:
 		b.WriteString("; SameSite=Lax")
 	case SameSiteStrictMode:
 		b.WriteString("; SameSite=Strict")
 	}
+	if c.Partitioned {
+		b.WriteString("; Partitioned")
+	}
+	if p := cookiePriority(c.Priority); p != "" {
+		b.WriteString("; Priority=")
+		b.WriteString(p)
+	} else if c.Priority != "" {
+		log.Printf("net/http: invalid Cookie.Priority %q; dropping priority attribute", c.Priority)
+	}
 	return b.String()
 }
 
@@ -371,9 +419,56 @@ func (c *Cookie) Valid() error {
 			return errors.New("http: invalid Cookie.Domain")
 		}
 	}
+	if c.SameSite == SameSiteNoneMode && !c.Secure {
+		return errors.New("http: SameSite=None Cookie requires Secure")
+	}
+	if c.Priority != "" && cookiePriority(c.Priority) == "" {
+		return errors.New("http: invalid Cookie.Priority")
+	}
+	return c.validPrefix()
+}
+
+// validPrefix reports whether the cookie meets the requirements of its name
+// prefix, if any: a "__Secure-" cookie must be Secure, and a "__Host-" cookie
+// must also have Path "/" and no Domain, so it is bound to the host that set it.
+// The prefixes are matched case-insensitively, like browsers do.
+func (c *Cookie) validPrefix() error {
+	switch {
+	case hasPrefixFold(c.Name, "__Secure-"):
+		if !c.Secure {
+			return errors.New("http: __Secure- Cookie requires Secure")
+		}
+	case hasPrefixFold(c.Name, "__Host-"):
+		if !c.Secure || c.Path != "/" || c.Domain != "" {
+			return errors.New("http: __Host- Cookie requires Secure, Path=/, and no Domain")
+		}
+	}
 	return nil
 }
 
+// hasPrefixFold reports whether s begins with prefix, ignoring ASCII case.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && ascii.EqualFold(s[:len(prefix)], prefix)
+}
+
+// cookiePriority returns the canonical form of the priority-value v,
+// or the empty string if v is not a known priority.
+func cookiePriority(v string) string {
+	lower, isASCII := ascii.ToLower(v)
+	if !isASCII {
+		return ""
+	}
+	switch lower {
+	case "low":
+		return "Low"
+	case "medium":
+		return "Medium"
+	case "high":
+		return "High"
+	}
+	return ""
+}
+
 // validCookieDomain reports whether v is a valid cookie domain-value.
 func validCookieDomain(v string) bool {
 	if isCookieDomainName(v) {
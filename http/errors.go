@@ -26,9 +26,86 @@ func (pe *ProtocolError) Is(err error) bool {
 
 var errInvalidPath = errors.New("invalid path")
 
+// Errors returned by ReadRequest when the request is malformed.
+// The returned errors wrap them, so test for them with errors.Is.
+var (
+	// ErrInvalidRequestLine is returned when the request line isn't
+	// "<method> <request-target> <protocol>".
+	ErrInvalidRequestLine = errors.New("http: invalid request line")
+
+	// ErrInvalidHeaderLine is returned when a header line isn't "<key>: <value>".
+	ErrInvalidHeaderLine = errors.New("http: invalid header line")
+
+	// ErrUnsupportedProtocol is returned when the protocol of the request
+	// isn't HTTP/1.x.
+	ErrUnsupportedProtocol = errors.New("http: unsupported protocol")
+
+	// ErrInvalidContentLength is returned when the Content-Length header
+	// isn't a non-negative number or has multiple differing values.
+	ErrInvalidContentLength = errors.New("http: invalid Content-Length")
+
+	// ErrHTTP2Preface is returned when the connection starts with the HTTP/2
+	// client connection preface, "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n", rather
+	// than an HTTP/1.x request. The preface is consumed.
+	ErrHTTP2Preface = errors.New("http: HTTP/2 connection preface received")
+)
+
+// statusError is an error used to respond to a request with an HTTP status.
+// The text should be plain text without user info or other embedded errors.
+// The underlying error err, if any, is returned by Unwrap and is never sent
+// to the client.
+type statusError struct {
+	code int
+	text string
+	err  error
+}
+
+func (e statusError) Error() string { return StatusText(e.code) + ": " + e.text }
+
+func (e statusError) Unwrap() error { return e.err }
+
+// badRequestError is a literal string (used by in the server in HTML,
+// unescaped) to tell the user why their request was bad. It should
+// be plain text without user info or other embedded errors.
+// The returned error wraps err.
+func badRequestError(e string, err error) error { return statusError{StatusBadRequest, e, err} }
+
+// ErrNotJSON is returned by Response's DecodeJSON method when the
+// response's Content-Type is not JSON and LenientJSON is not set, and
+// by Request's BindJSON method when the request's Content-Type is not JSON.
+var ErrNotJSON = errors.New("http: Content-Type isn't application/json")
+
+// ErrBodyReadAfterClose is returned when reading a [Request] or [Response]
+// Body after the body has been closed. This typically happens when the body is
+// read after an HTTP [Handler] calls WriteHeader or Write on its
+// [ResponseWriter].
+var ErrBodyReadAfterClose = errors.New("http: invalid Read on closed Body")
+
+// ErrBodyNotAllowed is returned by ResponseWriter.Write calls
+// when the HTTP method or response code does not permit a
+// body.
+var ErrBodyNotAllowed = errors.New("http: request method or response status code does not allow body")
+
+// ErrContentLength is returned by ResponseWriter.Write calls
+// when a Handler set a Content-Length response header with a
+// declared size and then attempted to write more bytes than
+// declared.
+var ErrContentLength = errors.New("http: wrote more than the declared Content-Length")
+
+// ErrHijacked is returned by ResponseWriter.Write calls when
+// the underlying connection has been hijacked using the
+// Hijacker interface. A zero-byte write on a hijacked
+// connection will return ErrHijacked without any other side
+// effects.
+var ErrHijacked = errors.New("http: connection has been hijacked")
+
 // ErrNoCookie is returned by Request's Cookie method when a cookie is not found.
 var ErrNoCookie = errors.New("http: named cookie not present")
 
+// ErrMissingSignedHeader is returned by Request's Sign method when a header
+// to be signed is not present in the request.
+var ErrMissingSignedHeader = errors.New("http: signed header not present")
+
 var (
 	// ErrNotSupported indicates that a feature is not supported.
 	//
@@ -47,6 +124,11 @@ var (
 	// request's Content-Type does not include a "boundary" parameter.
 	ErrMissingBoundary = &ProtocolError{"no multipart boundary param in Content-Type"}
 
+	// ErrInvalidBoundary is returned by Request.MultipartReader when the
+	// "boundary" parameter of the request's Content-Type isn't a valid
+	// boundary of RFC 2046, section 5.1.1.
+	ErrInvalidBoundary = &ProtocolError{"invalid multipart boundary param in Content-Type"}
+
 	// ErrNotMultipart is returned by Request.MultipartReader when the
 	// request's Content-Type is not multipart/form-data.
 	ErrNotMultipart = &ProtocolError{"request Content-Type isn't multipart/form-data"}
@@ -0,0 +1,436 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/textproto"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServeContent replies to the request using the content in the
+// provided ReadSeeker. Unlike io.Copy, ServeContent handles
+// If-Match, If-Unmodified-Since, If-None-Match, and If-Modified-Since
+// requests. Range requests are not supported, so the whole content is sent.
+//
+// If the response's Content-Type header is not set, ServeContent
+// first tries to deduce the type from name's file extension and,
+// if that fails, falls back to reading the first block of the content
+// and passing it to [SniffContentType].
+// The name is otherwise unused; in particular it can be empty and is
+// never sent in the response.
+//
+// If modtime is not the zero time or Unix epoch, ServeContent
+// includes it in a Last-Modified header in the response. If the
+// request includes an If-Modified-Since header, ServeContent uses
+// modtime to decide whether the content needs to be sent at all.
+//
+// The content's Seek method must work: ServeContent uses
+// a seek to the end of the content to determine its size.
+// Note that [*os.File] implements the [io.ReadSeeker] interface.
+//
+// If the caller has set w's ETag header formatted per RFC 7232, section 2.3,
+// ServeContent uses it to handle requests using If-Match or If-None-Match.
+// Otherwise, ServeContent derives a weak one from the content's size and
+// modtime, or, if modtime is the zero time or Unix epoch and the content is
+// at most maxETagHashSize bytes, sets it to the [ETag] of the content. Larger
+// content without a modtime gets no ETag, since hashing it would read all of
+// it on every request; callers that want one anyway can set it to [ETag].
+func ServeContent(w ResponseWriter, r *Request, name string, modtime time.Time, content io.ReadSeeker) {
+	setLastModified(w, modtime)
+	size, err := content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = content.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		// The Seeker's error text isn't sent to the client.
+		Error(w, "seeker can't seek", StatusInternalServerError)
+		return
+	}
+	if w.Header().Get("Etag") == "" {
+		if !isZeroTime(modtime) {
+			w.Header().Set("Etag", modTimeETag(modtime, size))
+		} else if size <= maxETagHashSize {
+			etag, err := ETag(content)
+			if err != nil {
+				Error(w, "error reading content", StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Etag", etag)
+		}
+	}
+	switch r.CheckPreconditions(modtime, w.Header().Get("Etag")) {
+	case StatusNotModified:
+		writeNotModified(w)
+		return
+	case StatusPreconditionFailed:
+		w.WriteHeader(StatusPreconditionFailed)
+		return
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		ctype := mime.TypeByExtension(filepath.Ext(name))
+		if ctype == "" {
+			// read a chunk to decide between utf-8 text and binary
+			var buf [sniffLen]byte
+			n, _ := io.ReadFull(content, buf[:])
+			ctype = SniffContentType(buf[:n])
+			_, err := content.Seek(0, io.SeekStart) // rewind to output whole file
+			if err != nil {
+				Error(w, "seeker can't seek", StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", ctype)
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(StatusOK)
+	if r.Method != "HEAD" {
+		io.CopyN(w, content, size)
+	}
+}
+
+// FileServerFS returns a handler that serves HTTP requests with the contents
+// of the file system fsys, e.g., an [embed.FS] of static assets. The URL path
+// of the request, cleaned and without its leading slash, names the file, so
+// "/css/site.css" serves "css/site.css". To serve fsys under a path other than
+// "/", wrap the handler with [StripPrefix].
+//
+// Files are served with [ServeContent], so conditional requests are handled.
+// A request for a directory is served its "index.html" file, if any; directories
+// aren't listed. Files that don't exist are answered with 404 (Not Found).
+func FileServerFS(fsys fs.FS) Handler {
+	return &fileHandler{fsys: fsys}
+}
+
+// fileHandler is the Handler returned by [FileServerFS].
+type fileHandler struct {
+	fsys fs.FS
+}
+
+func (f *fileHandler) ServeHTTP(w ResponseWriter, r *Request) {
+	name := path.Clean("/" + r.URL.Path)[1:]
+	if name == "" {
+		name = "."
+	}
+	file, info, err := openFile(f.fsys, name)
+	if err == nil && info.IsDir() {
+		file.Close()
+		file, info, err = openFile(f.fsys, path.Join(name, "index.html"))
+	}
+	if err != nil {
+		writeFSError(w, err)
+		return
+	}
+	defer file.Close()
+	if info.IsDir() {
+		NotFound(w, r)
+		return
+	}
+
+	// Files of most file systems, including embed.FS, can seek. The content
+	// of one that can't is read into memory to be served.
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(file)
+		if err != nil {
+			writeFSError(w, err)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+	ServeContent(w, r, info.Name(), info.ModTime(), content)
+}
+
+// openFile opens the file name of fsys and returns it with its info.
+func openFile(fsys fs.FS, name string) (fs.File, fs.FileInfo, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+// writeFSError replies to a request for a file that couldn't be served because
+// of err. The error text isn't sent to the client, since it may reveal the layout
+// of the file system.
+func writeFSError(w ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		Error(w, "404 page not found", StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		Error(w, "403 Forbidden", StatusForbidden)
+	default:
+		Error(w, "500 Internal Server Error", StatusInternalServerError)
+	}
+}
+
+// maxETagHashSize is the size of the largest content [ServeContent] hashes
+// to derive an ETag when there's no modtime.
+const maxETagHashSize = 64 << 10
+
+// ETag returns a strong entity tag for content: the quoted hex encoding of the
+// first 16 bytes of its SHA-256 hash, so the same content always has the same tag.
+//
+// The content is read from its start, and it is seeked back to its start afterwards.
+func ETag(content io.ReadSeeker) (string, error) {
+	_, err := content.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, content)
+	if err != nil {
+		return "", err
+	}
+	_, err = content.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, nil
+}
+
+// modTimeETag returns a weak entity tag built from the modification time
+// and size of content, which changes whenever either of them does. It's weak
+// since content rewritten within the clock's resolution keeps the same tag,
+// so it only satisfies If-None-Match, never If-Match.
+func modTimeETag(modtime time.Time, size int64) string {
+	return `W/"` + strconv.FormatInt(modtime.UnixNano(), 16) + "-" + strconv.FormatInt(size, 16) + `"`
+}
+
+// IsConditional reports whether r has any of the If-Match, If-None-Match,
+// If-Modified-Since, or If-Unmodified-Since headers, which make the
+// response depend on the state of the requested resource.
+// See [Request.CheckPreconditions].
+func (r *Request) IsConditional() bool {
+	for _, k := range []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		if r.Header.Get(k) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPreconditions evaluates the conditional headers of r against the
+// resource last modified at modtime with the entity tag etag, as specified
+// by RFC 7232, section 6. The modtime may be zero and the etag empty if
+// they're unknown.
+//
+// It returns [StatusNotModified] if a GET or HEAD request can be answered
+// with 304 (Not Modified), [StatusPreconditionFailed] if the request must be
+// rejected with 412 (Precondition Failed), or 0 if the request should be
+// served normally.
+func (r *Request) CheckPreconditions(modtime time.Time, etag string) int {
+	// This function carefully follows RFC 7232 section 6.
+	ch := checkIfMatch(r, etag)
+	if ch == condNone {
+		ch = checkIfUnmodifiedSince(r, modtime)
+	}
+	if ch == condFalse {
+		return StatusPreconditionFailed
+	}
+	switch checkIfNoneMatch(r, etag) {
+	case condFalse:
+		if r.Method == "GET" || r.Method == "HEAD" {
+			return StatusNotModified
+		}
+		return StatusPreconditionFailed
+	case condNone:
+		if checkIfModifiedSince(r, modtime) == condFalse {
+			return StatusNotModified
+		}
+	}
+	return 0
+}
+
+// scanETag determines if a syntactically valid ETag is present at s. If so,
+// the ETag and remaining text after consuming ETag is returned. Otherwise,
+// it returns "", "".
+func scanETag(s string) (etag string, remain string) {
+	s = textproto.TrimString(s)
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+	if len(s[start:]) < 2 || s[start] != '"' {
+		return "", ""
+	}
+	// ETag is either W/"text" or "text".
+	// See RFC 7232 2.3.
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		// Character values allowed in ETags.
+		case c == 0x21 || c >= 0x23 && c <= 0x7E || c >= 0x80:
+		case c == '"':
+			return s[:i+1], s[i+1:]
+		default:
+			return "", ""
+		}
+	}
+	return "", ""
+}
+
+// etagStrongMatch reports whether a and b match using strong ETag comparison.
+// Assumes a and b are valid ETags.
+func etagStrongMatch(a, b string) bool {
+	return a == b && a != "" && a[0] == '"'
+}
+
+// etagWeakMatch reports whether a and b match using weak ETag comparison.
+// Assumes a and b are valid ETags.
+func etagWeakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// condResult is the result of an HTTP request precondition check.
+// See https://tools.ietf.org/html/rfc7232 section 3.
+type condResult int
+
+const (
+	condNone condResult = iota
+	condTrue
+	condFalse
+)
+
+func checkIfMatch(r *Request, etag string) condResult {
+	im := r.Header.Get("If-Match")
+	if im == "" {
+		return condNone
+	}
+	for {
+		im = textproto.TrimString(im)
+		if len(im) == 0 {
+			break
+		}
+		if im[0] == ',' {
+			im = im[1:]
+			continue
+		}
+		if im[0] == '*' {
+			return condTrue
+		}
+		e, remain := scanETag(im)
+		if e == "" {
+			break
+		}
+		if etagStrongMatch(e, etag) {
+			return condTrue
+		}
+		im = remain
+	}
+
+	return condFalse
+}
+
+func checkIfUnmodifiedSince(r *Request, modtime time.Time) condResult {
+	ius := r.Header.Get("If-Unmodified-Since")
+	if ius == "" || isZeroTime(modtime) {
+		return condNone
+	}
+	t, err := ParseTime(ius)
+	if err != nil {
+		return condNone
+	}
+
+	// The Last-Modified header truncates sub-second precision so
+	// the modtime needs to be truncated too.
+	modtime = modtime.Truncate(time.Second)
+	if ret := modtime.Compare(t); ret <= 0 {
+		return condTrue
+	}
+	return condFalse
+}
+
+func checkIfNoneMatch(r *Request, etag string) condResult {
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" {
+		return condNone
+	}
+	buf := inm
+	for {
+		buf = textproto.TrimString(buf)
+		if len(buf) == 0 {
+			break
+		}
+		if buf[0] == ',' {
+			buf = buf[1:]
+			continue
+		}
+		if buf[0] == '*' {
+			return condFalse
+		}
+		e, remain := scanETag(buf)
+		if e == "" {
+			break
+		}
+		if etagWeakMatch(e, etag) {
+			return condFalse
+		}
+		buf = remain
+	}
+	return condTrue
+}
+
+func checkIfModifiedSince(r *Request, modtime time.Time) condResult {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return condNone
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || isZeroTime(modtime) {
+		return condNone
+	}
+	t, err := ParseTime(ims)
+	if err != nil {
+		return condNone
+	}
+	// The Last-Modified header truncates sub-second precision so
+	// the modtime needs to be truncated too.
+	modtime = modtime.Truncate(time.Second)
+	if ret := modtime.Compare(t); ret <= 0 {
+		return condFalse
+	}
+	return condTrue
+}
+
+var unixEpochTime = time.Unix(0, 0)
+
+// isZeroTime reports whether t is obviously unspecified (either zero or Unix()=0).
+func isZeroTime(t time.Time) bool {
+	return t.IsZero() || t.Equal(unixEpochTime)
+}
+
+func setLastModified(w ResponseWriter, modtime time.Time) {
+	if !isZeroTime(modtime) {
+		w.Header().Set("Last-Modified", FormatTime(modtime))
+	}
+}
+
+func writeNotModified(w ResponseWriter) {
+	// RFC 7232 section 4.1:
+	// a sender SHOULD NOT generate representation metadata other than the
+	// above listed fields unless said metadata exists for the purpose of
+	// guiding cache updates (e.g., Last-Modified might be useful if the
+	// response does not have an ETag field).
+	h := w.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	delete(h, "Content-Encoding")
+	if h.Get("Etag") != "" {
+		delete(h, "Last-Modified")
+	}
+	w.WriteHeader(StatusNotModified)
+}
@@ -1,5 +1,17 @@
 package http
 
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
 // A Handler responds to an HTTP request.
 //
 // [Handler.ServeHTTP] should write reply headers and data to the [ResponseWriter]
@@ -56,3 +68,290 @@ func (f HandlerFunc) ServeHTTP(w ResponseWriter, r *Request) {
 
 // Handlers is a map of handlers.
 type Handlers map[string]HandlerFunc
+
+// TimeoutHandler returns a [Handler] that runs h with the given time limit.
+//
+// The new Handler calls h.ServeHTTP to handle each request, but if a
+// call runs for longer than its time limit, the handler responds with
+// a 503 Service Unavailable error and the given message in its body.
+// (If msg is empty, a suitable default message will be sent.)
+// After such a timeout, writes by h to its [ResponseWriter] will return
+// [ErrHandlerTimeout].
+func TimeoutHandler(h Handler, dt time.Duration, msg string) Handler {
+	return &timeoutHandler{
+		handler: h,
+		body:    msg,
+		dt:      dt,
+	}
+}
+
+// SlowRequestLogger returns a middleware that warns on log about each request
+// whose handling takes longer than threshold, with the request's method,
+// path, and duration, e.g.:
+//
+//	slow request: GET /reports took 2.5s
+//
+// Requests are timed from the call of ServeHTTP until it returns. If log is
+// nil, the logger of [NewLogger] is used.
+func SlowRequestLogger(threshold time.Duration, log Log) func(Handler) Handler {
+	if log == nil {
+		log = NewLogger()
+	}
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			start := time.Now()
+			defer func() {
+				if d := time.Since(start); d > threshold {
+					log.Warn(fmt.Sprintf("slow request: %s %s took %v", r.Method, r.URL.Path, d))
+				}
+			}()
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MethodOverride returns a handler that lets clients unable to send some methods,
+// e.g., from behind a proxy only passing GET and POST, tunnel them in a POST
+// request. The method is taken from the "X-HTTP-Method-Override" header or,
+// without it, from the "_method" field of an application/x-www-form-urlencoded
+// body, and h is called with a copy of the request using that method. Other
+// bodies, e.g., multipart forms, are left unread for h.
+//
+// Only PUT, PATCH, and DELETE can be tunneled, so a POST can't be turned into a
+// safe method, like GET, which caches and CSRF protections may trust. Requests
+// with other methods are passed to h unchanged.
+func MethodOverride(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Method != "POST" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		m := r.Header.Get("X-HTTP-Method-Override")
+		if m == "" {
+			// ParseForm only reads urlencoded bodies.
+			r.ParseForm()
+			m = r.PostForm.Get("_method")
+		}
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if m == "PUT" || m == "PATCH" || m == "DELETE" {
+			r2 := new(Request)
+			*r2 = *r
+			r2.Method = m
+			r = r2
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// BasicAuthMiddleware returns a middleware that requires HTTP Basic
+// Authentication. The credentials of each request, from [Request.BasicAuth],
+// are passed to check, and only requests it accepts are passed on. The others,
+// including those without credentials, are answered with 401 (Unauthorized)
+// and a "WWW-Authenticate" header challenging the client for the realm.
+//
+// To avoid leaking the credentials through timing, check should compare them
+// in constant time, e.g., with [crypto/subtle.ConstantTimeCompare].
+func BasicAuthMiddleware(realm string, check func(user, pass string) bool) func(Handler) Handler {
+	challenge := `Basic realm="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(realm) + `"`
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			user, pass, ok := r.BasicAuth()
+			if !ok || !check(user, pass) {
+				w.Header().Set("WWW-Authenticate", challenge)
+				Error(w, StatusText(StatusUnauthorized), StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ErrHandlerTimeout is returned on [ResponseWriter] Write calls
+// in handlers which have timed out.
+var ErrHandlerTimeout = errors.New("http: Handler timeout")
+
+type timeoutHandler struct {
+	handler Handler
+	body    string
+	dt      time.Duration
+}
+
+func (h *timeoutHandler) errorBody() string {
+	if h.body != "" {
+		return h.body
+	}
+	return "<html><head><title>Timeout</title></head><body><h1>Timeout</h1></body></html>"
+}
+
+func (h *timeoutHandler) ServeHTTP(w ResponseWriter, r *Request) {
+	ctx, cancelCtx := context.WithTimeout(r.Context(), h.dt)
+	defer cancelCtx()
+	r = r.WithContext(ctx)
+	var body *timeoutBody
+	if r.Body != nil && r.Body != NoBody {
+		body = &timeoutBody{ReadCloser: r.Body}
+		r.Body = body
+	}
+	done := make(chan struct{})
+	tw := &timeoutWriter{
+		w:   w,
+		h:   make(Header),
+		req: r,
+		ctx: ctx,
+	}
+	panicChan := make(chan any, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+		h.handler.ServeHTTP(tw, r)
+		close(done)
+	}()
+	select {
+	case p := <-panicChan:
+		panic(p)
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		if tw.checkDoneLocked() != nil {
+			// The handler returned after its time limit, so writes it made
+			// since have failed and its response is incomplete.
+			h.writeDoneLocked(w, tw)
+			return
+		}
+		dst := w.Header()
+		for k, vv := range tw.h {
+			dst[k] = vv
+		}
+		if !tw.wroteHeader {
+			tw.code = StatusOK
+		}
+		w.WriteHeader(tw.code)
+		w.Write(tw.wbuf.Bytes())
+	case <-ctx.Done():
+		if body != nil {
+			// The handler may still be reading the body, from the reader
+			// the server reads the next request from once ServeHTTP returns.
+			// Cut it off, and don't reuse the connection, since the body may
+			// have been read partway.
+			body.cutOff(w)
+			w.Header().Set("Connection", "close")
+		}
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.checkDoneLocked()
+		h.writeDoneLocked(w, tw)
+	}
+}
+
+// timeoutBody is the request body of the handler run by [timeoutHandler].
+// Once the handler times out, its reads fail with [ErrHandlerTimeout].
+type timeoutBody struct {
+	io.ReadCloser
+	mu     sync.Mutex // held during reads
+	closed atomic.Bool
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed.Load() {
+		return 0, ErrHandlerTimeout
+	}
+	return b.ReadCloser.Read(p)
+}
+
+func (b *timeoutBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed.Load() {
+		return nil
+	}
+	return b.ReadCloser.Close()
+}
+
+// cutOff fails further reads of b and returns once no read is in progress.
+// A read blocked on the connection of w is interrupted with a read deadline
+// in the past, after which the connection can't be reused.
+func (b *timeoutBody) cutOff(w ResponseWriter) {
+	b.closed.Store(true)
+	if !b.mu.TryLock() {
+		if rw, ok := w.(*responseWriter); ok {
+			rw.conn.SetReadDeadline(aLongTimeAgo)
+		}
+		b.mu.Lock()
+	}
+	b.mu.Unlock()
+}
+
+// writeDoneLocked replies 503 (Service Unavailable) in place of the response of
+// the handler, whose context is done, with the timeout message if it timed out.
+func (h *timeoutHandler) writeDoneLocked(w ResponseWriter, tw *timeoutWriter) {
+	w.WriteHeader(StatusServiceUnavailable)
+	if tw.err == ErrHandlerTimeout {
+		io.WriteString(w, h.errorBody())
+	}
+}
+
+// timeoutWriter buffers the response of the handler run by [timeoutHandler],
+// so it can be discarded if the handler times out.
+type timeoutWriter struct {
+	w    ResponseWriter
+	h    Header
+	wbuf bytes.Buffer
+	req  *Request
+	ctx  context.Context // context of the handler, done at its time limit
+
+	mu          sync.Mutex
+	err         error
+	wroteHeader bool
+	code        int
+}
+
+func (tw *timeoutWriter) Header() Header { return tw.h }
+
+// checkDoneLocked fails further writes once the handler's context is done,
+// with [ErrHandlerTimeout] if it timed out, and returns the error they fail
+// with. The writer checks the context itself, rather than waiting to be told,
+// so a handler that sees the context done can't write before the timeout is
+// noticed.
+func (tw *timeoutWriter) checkDoneLocked() error {
+	if tw.err == nil {
+		switch err := tw.ctx.Err(); err {
+		case nil:
+		case context.DeadlineExceeded:
+			tw.err = ErrHandlerTimeout
+		default:
+			tw.err = err
+		}
+	}
+	return tw.err
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if err := tw.checkDoneLocked(); err != nil {
+		return 0, err
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(StatusOK)
+	}
+	return tw.wbuf.Write(p)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	if tw.checkDoneLocked() != nil || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.code = code
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(code)
+}
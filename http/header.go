@@ -1,7 +1,13 @@
 package http
 
 import (
+	"fmt"
+	"io"
 	gohttp "net/http"
+	"net/textproto"
+	"slices"
+	"sort"
+	"strings"
 )
 
 // Header is the metadata of the request or response.
@@ -11,5 +17,99 @@ type Header = gohttp.Header // map[string][]string
 
 // NewHeader creates a new Header.
 func NewHeader() Header {
-	return Header(gohttp.Header{})
+	return Header{}
+}
+
+// HeaderHas reports whether h has the provided key defined, even if it's
+// set to 0-length slice.
+// The key is case insensitive; it is canonicalized by
+// [textproto.CanonicalMIMEHeaderKey].
+func HeaderHas(h Header, key string) bool {
+	_, ok := h[textproto.CanonicalMIMEHeaderKey(key)]
+	return ok
+}
+
+// HeaderGetDefault is like [Header.Get], but returns def if there are no
+// values associated with the given key. A header present with an empty
+// value is not absent, so its value "" is returned.
+func HeaderGetDefault(h Header, key, def string) string {
+	if v := h.Values(key); len(v) > 0 {
+		return v[0]
+	}
+	return def
+}
+
+// HeaderGetAll returns a copy of all values associated with the given key,
+// or nil if there are none. Like [Header.Get], it is case insensitive.
+// Unlike [Header.Values], the returned slice can be modified by the caller.
+func HeaderGetAll(h Header, key string) []string {
+	return slices.Clone(h.Values(key))
+}
+
+// HeaderWriteOrdered writes the headers of h named by keys in wire format,
+// in the order given, e.g., for HTTP message signatures where canonical
+// ordering matters. Keys are canonicalized by [textproto.CanonicalMIMEHeaderKey]
+// and keys not present in h are skipped. Headers not named by keys are not written.
+func HeaderWriteOrdered(w io.Writer, h Header, keys []string) error {
+	for _, k := range keys {
+		k = textproto.CanonicalMIMEHeaderKey(k)
+		if vv, ok := h[k]; ok {
+			if err := (Header{k: vv}).Write(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hopHeaders are the hop-by-hop headers of RFC 7230, section 6.1, which
+// apply only to a single connection and must not be forwarded by proxies.
+// Proxy-Authorization is left out, as it carries the client's credentials
+// for the proxy it talks to.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Connection", // non-standard but still sent by some clients
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopByHopHeaders removes the hop-by-hop headers from h, including
+// the ones named by its Connection header.
+func removeHopByHopHeaders(h Header) {
+	for _, f := range h["Connection"] {
+		for _, sf := range strings.Split(f, ",") {
+			if sf = textproto.TrimString(sf); sf != "" {
+				h.Del(sf)
+			}
+		}
+	}
+	for _, k := range hopHeaders {
+		delete(h, k)
+	}
+}
+
+// diffHeader returns a "Header[<key>]: <a values> != <b values>" line for each
+// key whose values differ between a and b, sorted by key.
+func diffHeader(a, b Header) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var diffs []string
+	for _, k := range keys {
+		if !slices.Equal(a[k], b[k]) {
+			diffs = append(diffs, fmt.Sprintf("Header[%s]: %q != %q", k, a[k], b[k]))
+		}
+	}
+	return diffs
 }
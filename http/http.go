@@ -56,6 +56,16 @@ func (e *MaxBytesError) Error() string {
 	return "http: request body too large"
 }
 
+// ResponseTooLargeError is returned when reading a response body beyond the
+// Client's MaxResponseBytes. It wraps the [*MaxBytesError] holding the limit.
+type ResponseTooLargeError struct {
+	Err *MaxBytesError
+}
+
+func (e *ResponseTooLargeError) Error() string { return "http: response body too large" }
+
+func (e *ResponseTooLargeError) Unwrap() error { return e.Err }
+
 type maxBytesReader struct {
 	w   ResponseWriter
 	r   io.ReadCloser // underlying reader
@@ -1,7 +1,13 @@
 package http
 
 import (
+	"slices"
 	"sort"
+	"strings"
+	"sync"
+
+	"github.com/curol/network/http/internal/timeformat"
+	"github.com/curol/network/url"
 )
 
 // DefaultServeMux is the default [ServeMux] used by [Serve].
@@ -145,9 +151,17 @@ func init() {
 //	}
 //
 // ```
+//
+// Handlers can be registered while the Mux is serving requests.
+//
+// A request for a path registered with other methods only, e.g., a POST for
+// "GET /items", is answered with [MethodNotAllowed] and the methods of the path
+// in the Allow header, rather than a 404.
 type Mux struct {
-	m     map[string]muxEntry
-	hosts bool
+	mu      sync.RWMutex // guards the following
+	m       map[string]muxEntry
+	methods map[string][]string // methods registered for each host and path key
+	hosts   bool
 }
 
 // NewMux returns a new Mux.
@@ -158,7 +172,8 @@ func NewMux() *Mux {
 // ServeHttp finds a handler for the request and calls that handler's ServeHTTP method to handle the request.
 func (m *Mux) ServeHTTP(w ResponseWriter, r *Request) {
 	// Find handler
-	h, _ := m.findHandler(r.Host, r.URL.Path)
+	h, pattern := m.findHandler(r.Method, r.Host, r.URL.EscapedPath())
+	r.pat = pattern
 	if h == nil {
 		h = NotFoundHandler()
 	}
@@ -186,7 +201,17 @@ func (mux *Mux) register(pattern string, handler Handler) {
 	if handler == nil {
 		panic("http: nil handler")
 	}
-	if _, exist := mux.m[string(pattern)]; exist {
+	method, rest := splitMethod(pattern)
+	if rest == "" {
+		panic("http: invalid pattern " + pattern)
+	}
+	key, err := patternKey(rest)
+	if err != nil {
+		panic("http: invalid pattern " + pattern + ": " + err.Error())
+	}
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	if _, exist := mux.m[methodKey(method, key)]; exist {
 		panic("http: multiple registrations for " + pattern)
 	}
 	if mux.m == nil {
@@ -194,8 +219,14 @@ func (mux *Mux) register(pattern string, handler Handler) {
 	}
 
 	e := muxEntry{h: handler, pattern: pattern}
-	mux.m[pattern] = e
-	if pattern[0] != '/' {
+	mux.m[methodKey(method, key)] = e
+	if method != "" {
+		if mux.methods == nil {
+			mux.methods = make(map[string][]string)
+		}
+		mux.methods[key] = append(mux.methods[key], method)
+	}
+	if rest[0] != '/' {
 		mux.hosts = true
 	}
 }
@@ -229,24 +260,115 @@ func Handle(pattern string, handler Handler) {
 
 // handler is the main implementation of Handler.
 // The path is known to be in canonical form, except for CONNECT methods.
-func (mux *Mux) findHandler(host, path string) (h Handler, pattern string) {
+func (mux *Mux) findHandler(method, host, path string) (h Handler, pattern string) {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	if mux.m == nil {
+		return NotFoundHandler(), ""
+	}
+	key, err := pathKey(path)
+	if err != nil {
+		return nil, ""
+	}
 	// Host-specific pattern takes precedence over generic ones
-	if mux.m != nil {
-		// if e, ok := mux.m[path]; ok {
-		// 	return e.h, e.pattern
-		// }
-		return mux.match(path)
+	hosts := []string{""}
+	if mux.hosts {
+		hosts = []string{muxHost(host), ""}
+	}
+	for _, host := range hosts {
+		if h, pattern = mux.match(method, host+key); h != nil {
+			return h, pattern
+		}
+	}
+	// The path may be registered with other methods.
+	var allowed []string
+	for _, host := range hosts {
+		allowed = append(allowed, mux.methods[host+key]...)
+	}
+	if len(allowed) == 0 {
+		return nil, ""
 	}
-	return NotFoundHandler(), ""
+	if slices.Contains(allowed, "GET") {
+		allowed = append(allowed, "HEAD")
+	}
+	return HandlerFunc(func(w ResponseWriter, r *Request) { MethodNotAllowed(w, allowed) }), ""
 }
 
-// Find a handler on a handler map given a path string.
-// Most-specific (longest) pattern wins.
-func (mux *Mux) match(path string) (h Handler, pattern string) {
-	// Check for exact match first.
-	v, ok := mux.m[path]
-	if ok {
+// Find a handler on a handler map given a request method and the key of a host,
+// or "" for patterns without one, and path.
+// A pattern with the method takes precedence over one without it, and a
+// pattern with GET also matches HEAD requests.
+func (mux *Mux) match(method, key string) (h Handler, pattern string) {
+	if v, ok := mux.m[methodKey(method, key)]; ok {
+		return v.h, v.pattern
+	}
+	if method == "HEAD" {
+		if v, ok := mux.m[methodKey("GET", key)]; ok {
+			return v.h, v.pattern
+		}
+	}
+	if v, ok := mux.m[key]; ok {
 		return v.h, v.pattern
 	}
 	return nil, ""
 }
+
+// splitMethod splits the optional "METHOD " prefix off pattern. A space after
+// the first slash is part of the path.
+func splitMethod(pattern string) (method, rest string) {
+	if i := strings.IndexAny(pattern, " /"); i >= 0 && pattern[i] == ' ' {
+		return pattern[:i], pattern[i+1:]
+	}
+	return "", pattern
+}
+
+// methodKey returns the key in the handler map of a pattern with method and the
+// host and path key, which is key itself for a pattern without a method.
+func methodKey(method, key string) string {
+	if method == "" {
+		return key
+	}
+	return method + " " + key
+}
+
+// muxHost returns the host of a request or pattern as matched by a Mux:
+// without its port and, for an IPv6 address, without its zone, but with its
+// brackets. For example, "[fe80::1%en0]:8080" is matched as "[fe80::1]".
+// A zone only scopes the address to a network interface of the client, and
+// isn't sent in the Host header by conforming clients.
+func muxHost(host string) string {
+	host = timeformat.RemoveZone(host)
+	if hasPort(host) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	return host
+}
+
+// patternKey returns the key of pattern in the handler map.
+// The host of the pattern, if any, is keyed by [muxHost], while its path is keyed by [pathKey].
+func patternKey(pattern string) (string, error) {
+	i := strings.IndexByte(pattern, '/')
+	if i < 0 {
+		return pattern, nil
+	}
+	key, err := pathKey(pattern[i:])
+	if err != nil {
+		return "", err
+	}
+	return muxHost(pattern[:i]) + key, nil
+}
+
+// pathKey unescapes each "/"-separated segment of the escaped path and escapes it again canonically,
+// so paths are compared segment by segment.
+// For example, "/%61" and "/a" have the same key, while "/a%2Fb" (one segment "a/b") and "/a/b" don't.
+func pathKey(path string) (string, error) {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		s, err := url.PathUnescape(seg)
+		if err != nil {
+			return "", err
+		}
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/"), nil
+}
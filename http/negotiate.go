@@ -0,0 +1,97 @@
+package http
+
+import (
+	"strconv"
+	"strings"
+)
+
+// AcceptSpec is a media range of an Accept header and its quality value.
+type AcceptSpec struct {
+	Value string  // e.g., "text/html", "text/*", or "*/*", in lowercase
+	Q     float64 // from 0 (not acceptable) to 1
+}
+
+// ParseAccept parses the value of an Accept header, e.g.,
+// "text/html, application/json;q=0.9, */*;q=0.1", as specified by
+// RFC 7231, section 5.3.2. The specs are returned in the order of the header.
+//
+// A media range without a "q" parameter has a quality of 1. Media ranges
+// with a malformed quality value are skipped, and other parameters are ignored.
+func ParseAccept(accept string) []AcceptSpec {
+	var specs []AcceptSpec
+	for _, part := range strings.Split(accept, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		value = strings.ToLower(trimOWS(value))
+		if value == "" {
+			continue
+		}
+		spec := AcceptSpec{Value: value, Q: 1}
+		ok := true
+		for _, param := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(param, "=")
+			if !strings.EqualFold(trimOWS(k), "q") {
+				continue
+			}
+			q, err := strconv.ParseFloat(trimOWS(v), 64)
+			if err != nil || q < 0 || q > 1 {
+				ok = false
+				break
+			}
+			spec.Q = q
+		}
+		if ok {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// Negotiate returns the media type of offers that best matches the Accept
+// header of r, or "" if none of them is acceptable. For example, a handler
+// offering []string{"application/json", "application/xml"} replies in XML
+// only if the client prefers it.
+//
+// Each offer gets the quality of the most specific media range matching it,
+// so "text/html" outranks "text/*", which outranks "*/*". The offer with the
+// highest quality wins, and ties go to the offer listed first. Without an
+// Accept header, any type is acceptable, so the first offer is returned.
+func Negotiate(r *Request, offers []string) string {
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+	specs := ParseAccept(strings.Join(accept, ","))
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, spec := range specs {
+			if s := acceptMatch(spec.Value, offer); s > specificity {
+				q, specificity = spec.Q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// acceptMatch returns how specifically the media range matches the media
+// type offer: 2 for the same type, 1 for "type/*", 0 for "*/*", or -1 if
+// it doesn't match.
+func acceptMatch(mediaRange, offer string) int {
+	offer = strings.ToLower(offer)
+	switch {
+	case mediaRange == offer:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(offer, mediaRange[:len(mediaRange)-1]):
+		return 1
+	}
+	return -1
+}
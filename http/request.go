@@ -12,15 +12,28 @@ package http
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime"
 	"mime/multipart"
+	"net/textproto"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/curol/network/http/internal"
+	"github.com/curol/network/http/internal/ascii"
 	"github.com/curol/network/http/internal/timeformat"
 	url "github.com/curol/network/url"
 )
@@ -133,6 +146,11 @@ type Request struct {
 	ContentLength int64
 
 	// ContentType specifies the request body's MIME type.
+	//
+	// For server requests without a Content-Type header, it is the type
+	// sniffed from the start of a body with a Content-Length (see
+	// [SniffContentType]) if it was received along with the header.
+	// The Header is left unchanged.
 	ContentType string
 
 	// RemoteAddr allows HTTP servers and other software to record
@@ -198,9 +216,19 @@ type Request struct {
 	// Additional headers sent after the request body.
 	Trailer Header
 
+	// ctx is either the client or server context. It should only
+	// be modified via copying the whole Request using Clone or WithContext.
+	// It is unexported to prevent people from using Context wrong
+	// and mutating the contexts held by callers of the same request.
+	ctx context.Context
+
+	// bytesRead counts the bytes of a received request read so far,
+	// shared by the copies of the request. See BytesRead.
+	bytesRead *int64
+
 	// TODO: Add misc fields?
 	// The following fields are for requests matched by ServeMux.
-	// pat         *pattern          // the pattern that matched
+	pat         string            // the pattern that matched
 	matches     []string          // values for the matching wildcards in pat
 	otherValues map[string]string // for calls to SetPathValue that don't match a wildcard
 }
@@ -217,6 +245,87 @@ func NewRequest(method string, address string, headers map[string][]string, body
 	)
 }
 
+// NewRequestProto is like [NewRequest], but the request is sent with the
+// protocol `proto`, either "HTTP/1.1" or "HTTP/1.0", instead of the default
+// "HTTP/1.1". It returns an error wrapping [ErrUnsupportedProtocol] for
+// other protocols.
+func NewRequestProto(method string, address string, proto string, headers map[string][]string, body io.Reader) (*Request, error) {
+	return newRequest(method, address, proto, headers, body)
+}
+
+// NewJSONRequest is for client requests with a JSON body.
+// It marshals `v` and creates a new request with the JSON as its body,
+// setting the Content-Type and Content-Length headers.
+// The returned request's GetBody replays the JSON body.
+func NewJSONRequest(method string, address string, v any) (*Request, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	header := map[string][]string{
+		"Content-Type":   {"application/json"},
+		"Content-Length": {strconv.Itoa(len(b))},
+	}
+	return NewRequest(method, address, header, bytes.NewReader(b))
+}
+
+// NewFormRequest is for client requests with a form body.
+// It encodes `form` as "application/x-www-form-urlencoded" and creates a new request with it as its body,
+// setting the Content-Type and Content-Length headers.
+// The returned request's GetBody replays the encoded form.
+func NewFormRequest(method string, address string, form url.Values) (*Request, error) {
+	b := form.Encode()
+	header := map[string][]string{
+		"Content-Type":   {"application/x-www-form-urlencoded"},
+		"Content-Length": {strconv.Itoa(len(b))},
+	}
+	return NewRequest(method, address, header, strings.NewReader(b))
+}
+
+// NewMultipartRequest is for client requests with a "multipart/form-data" body, e.g., file uploads.
+// It writes each of `fields` as a form field and each of `files` as a file part,
+// where the map key is used for both the field name and the file name.
+// The body is buffered, so the returned request's GetBody replays it.
+func NewMultipartRequest(method string, address string, fields map[string]string, files map[string]io.Reader) (*Request, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.SetBoundary(randomBoundary()); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := mw.WriteField(k, fields[k]); err != nil {
+			return nil, err
+		}
+	}
+	keys = keys[:0]
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fw, err := mw.CreateFormFile(k, k)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(fw, files[k]); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	header := map[string][]string{
+		"Content-Type":   {mw.FormDataContentType()},
+		"Content-Length": {strconv.Itoa(buf.Len())},
+	}
+	return NewRequest(method, address, header, &buf)
+}
+
 func newRequest(method string, address string, prot string, header map[string][]string, body io.Reader) (*Request, error) {
 	if method == "" {
 		method = "GET"
@@ -226,8 +335,8 @@ func newRequest(method string, address string, prot string, header map[string][]
 	address = strings.TrimSpace(address)
 	prot = strings.TrimSpace(prot)
 	major, minor, ok := ParseHTTPVersion(prot) // parse protocol
-	if !ok {
-		return nil, fmt.Errorf("invalid protocol")
+	if !ok || major != 1 {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedProtocol, prot)
 	}
 
 	// 2. Parse URL
@@ -236,8 +345,8 @@ func newRequest(method string, address string, prot string, header map[string][]
 		rawurl = "http://" + rawurl
 	}
 	u, err := parseURL(rawurl) // parse url
-	if err != nil {            // TODO: Handle error
-		panic(err)
+	if err != nil {
+		return nil, err
 	}
 	u.Host = removeEmptyPort(u.Host) // the host's colon:port should be normalized. See Issue 14836.
 
@@ -263,7 +372,7 @@ func newRequest(method string, address string, prot string, header map[string][]
 	req := &Request{
 		// Request line
 		Method:     method,
-		Proto:      protocol,
+		Proto:      prot,
 		ProtoMajor: major,
 		ProtoMinor: minor,
 		// RequestURI: "", // Don't set RequestURI for client requests
@@ -344,6 +453,21 @@ func newRequest(method string, address string, prot string, header map[string][]
 //
 // Write is used after the request has been parsed and validated.
 func (r *Request) Write(w io.Writer) error {
+	return r.bufferedWrite(w, false)
+}
+
+// WriteProxy is like [Request.Write] but writes the request in the form
+// expected by an HTTP proxy. In particular, [Request.WriteProxy] writes the
+// initial Request-URI line of the request with an absolute URI, per
+// section 5.3 of RFC 7230, including the scheme and host.
+// The hop-by-hop headers, such as Connection and Upgrade, and the headers
+// named by Connection aren't forwarded to the proxy.
+func (r *Request) WriteProxy(w io.Writer) error {
+	return r.bufferedWrite(w, true)
+}
+
+// bufferedWrite writes r to w, in the form expected by a proxy if usingProxy is set.
+func (r *Request) bufferedWrite(w io.Writer, usingProxy bool) error {
 	if w == nil {
 		return errors.New("http: nil Writer")
 	}
@@ -353,39 +477,49 @@ func (r *Request) Write(w io.Writer) error {
 	// - 'w.Flush' flushes the buffer
 
 	// 1.) Wrap the writer in a bufio Writer if it's not already buffered.
-	// Don't always call NewWriter, as that forces a bytes.Buffer
-	// and other small bufio Writers to have a minimum 4k buffer
-	// size.
-
-	// var bw *bufio.Writer
-	// if _, ok := w.(*bufio.Writer); !ok {
-	// 	bw = bufio.NewWriter(w)
-	// } else {
-	// 	bw = w.(*bufio.Writer)
-	// var bw *bufio.Writer
-	// if _, ok := w.(io.ByteWriter); !ok { // check if writer is bufferd
-	// 	bw = bufio.NewWriter(w)
-	// 	w = bw
-	// }
+	// A *bufio.Writer passed in by the caller is owned by the caller, who is
+	// responsible for flushing it. One allocated here must be flushed before
+	// returning or the output is lost.
 	switch v := w.(type) {
 	case *bufio.Writer:
-		return r.write(v)
+		return r.write(v, usingProxy)
 	default:
-		return r.write(bufio.NewWriter(w))
+		bw := bufio.NewWriter(w)
+		if err := r.write(bw, usingProxy); err != nil {
+			return err
+		}
+		return bw.Flush()
 	}
 }
 
-// write serializes r to w.
-func (r *Request) write(w *bufio.Writer) error {
-	// 1. Serialize and write the request line
-	_, err := fmt.Fprintf(w, "%s %s %s\r\n", r.Method, r.URL.RequestURI(), r.Proto)
-	if err != nil {
-		return err
+// reqWriteExcludeHeader is the set of headers write doesn't copy from
+// the request's Header, since it writes them itself.
+var reqWriteExcludeHeader = map[string]bool{
+	"Host":              true,
+	"User-Agent":        true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Trailer":           true,
+}
+
+// reqWriteExcludeProxyHeader is reqWriteExcludeHeader plus the hop-by-hop
+// headers, which are meant for the client's connection and not forwarded
+// by [Request.WriteProxy].
+var reqWriteExcludeProxyHeader = func() map[string]bool {
+	m := make(map[string]bool, len(reqWriteExcludeHeader)+len(hopHeaders))
+	for k := range reqWriteExcludeHeader {
+		m[k] = true
+	}
+	for _, k := range hopHeaders {
+		m[k] = true
 	}
+	return m
+}()
 
-	// 2. Serialize and write the headers
-	// TODO: Write Transfer-Encoding, Trailer, and other headers
-	// Find the target host. Prefer the Host: header, but if that
+// write serializes r to w.
+// If usingProxy is set, the request-target is the absolute URL of the request.
+func (r *Request) write(w *bufio.Writer, usingProxy bool) error {
+	// 1. Find the target host. Prefer the Host: header, but if that
 	// is not given, use the host from the request URL.
 	var errMissingHost = errors.New("http: Request.Write on Request with no Host or URL set")
 	host := r.Host
@@ -418,6 +552,25 @@ func (r *Request) write(w *bufio.Writer) error {
 		// probably can't do anything useful with an empty Host header.
 		host = ""
 	}
+
+	// 2. Serialize and write the request line
+	ruri := r.URL.RequestURI()
+	if usingProxy && r.URL.Scheme != "" && r.URL.Opaque == "" {
+		ruri = r.URL.Scheme + "://" + host + ruri
+	} else if r.Method == "CONNECT" && r.URL.Path == "" {
+		// CONNECT requests normally give just the host and port, not a full URL.
+		ruri = host
+		if r.URL.Opaque != "" {
+			ruri = r.URL.Opaque
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s %s %s\r\n", r.Method, ruri, r.Proto)
+	if err != nil {
+		return err
+	}
+
+	// 3. Serialize and write the headers
+	// TODO: Write Transfer-Encoding, Trailer, and other headers
 	fmt.Fprintf(w, "Host: %s\r\n", host) // write host
 
 	// Use the defaultUserAgent unless the Header contains one, which
@@ -430,23 +583,36 @@ func (r *Request) write(w *bufio.Writer) error {
 		fmt.Fprintf(w, "User-Agent: %s\r\n", userAgent) // write user agent
 	}
 
-	cl := r.ContentLength
-	if cl < 0 && r.Body != nil {
+	// A NoBody body is an explicit zero-length body, so it is never chunked.
+	cl := r.outgoingLength()
+	body := r.Body
+	if cl < 0 && body != nil && body != NoBody && r.ProtoMajor == 1 && r.ProtoMinor == 0 {
+		// HTTP/1.0 has no chunked transfer coding, so a body of unknown
+		// length is read into memory to be sent with its length.
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		cl, body = int64(len(b)), io.NopCloser(bytes.NewReader(b))
+	}
+	if cl < 0 && body != nil && body != NoBody {
 		fmt.Fprintf(w, "Transfer-Encoding: chunked\r\n") // write transfer encoding
 	} else if cl > 0 {
 		fmt.Fprintf(w, "Content-Length: %d\r\n", cl) // write content length
-	} else {
-		// TODO: Handle error
+	} else if r.expectsBody() && (body == nil || body == NoBody) {
+		fmt.Fprintf(w, "Content-Length: 0\r\n") // write explicit zero content length
 	}
 
-	var reqWriteExcludeHeader = map[string]bool{
-		"Host":              true,
-		"User-Agent":        true,
-		"Content-Length":    true,
-		"Transfer-Encoding": true,
-		"Trailer":           true,
+	header, exclude := r.Header, reqWriteExcludeHeader
+	if usingProxy {
+		exclude = reqWriteExcludeProxyHeader
+		if HeaderHas(header, "Connection") {
+			// The fields named by Connection are hop-by-hop too.
+			header = header.Clone()
+			removeHopByHopHeaders(header)
+		}
 	}
-	err = r.Header.WriteSubset(w, reqWriteExcludeHeader) // write headers
+	err = header.WriteSubset(w, exclude) // write headers
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -466,9 +632,21 @@ func (r *Request) write(w *bufio.Writer) error {
 	// }
 
 	// 5. Write body
-	if r.Body != nil {
+	if cl < 0 && body != nil && body != NoBody {
+		cw := internal.NewChunkedWriter(w)
+		_, err := io.Copy(cw, body)
+		if err == nil {
+			err = cw.Close() // terminating chunk
+		}
+		if err == nil {
+			_, err = io.WriteString(w, "\r\n") // empty trailer
+		}
+		if err != nil {
+			return err
+		}
+	} else if body != nil && body != NoBody {
 		// TODO: Check content length not larger than max memory
-		_, err := io.CopyN(w, r.Body, r.ContentLength) // write body to w
+		_, err := io.CopyN(w, body, cl) // write body to w
 		if err != nil {
 			return err
 		}
@@ -481,6 +659,8 @@ func (r *Request) write(w *bufio.Writer) error {
 	return nil
 }
 
+// Clone returns a deep copy of r. Its URL, Header and Trailer can be changed
+// without affecting r; the Body is shared.
 func (r *Request) Clone() *Request {
 	clone := new(Request)
 	clone.Method = r.Method
@@ -488,22 +668,138 @@ func (r *Request) Clone() *Request {
 	clone.ProtoMajor = r.ProtoMajor
 	clone.ProtoMinor = r.ProtoMinor
 	clone.RequestURI = r.RequestURI
-	clone.URL = r.URL
+	clone.URL = cloneURL(r.URL)
 	clone.Header = r.Header.Clone()
 	clone.Body = r.Body
 	clone.ContentLength = r.ContentLength
 	clone.ContentType = r.ContentType
 	clone.RemoteAddress = r.RemoteAddress
+	clone.Host = r.Host
 	clone.GetBody = r.GetBody
 	clone.Form = r.Form
+	clone.PostForm = r.PostForm
 	clone.MultipartForm = r.MultipartForm
 	clone.TLS = r.TLS
 	clone.Trailer = r.Trailer.Clone()
 	clone.TransferEncoding = r.TransferEncoding
 	clone.Close = r.Close
+	clone.ctx = r.ctx
+	clone.bytesRead = r.bytesRead
+	clone.pat = r.pat
+	clone.matches = r.matches
+	clone.otherValues = r.otherValues
 	return clone
 }
 
+// cloneURL returns a copy of u, including its Userinfo.
+func cloneURL(u *url.URL) *url.URL {
+	if u == nil {
+		return nil
+	}
+	u2 := new(url.URL)
+	*u2 = *u
+	if u.User != nil {
+		u2.User = new(url.Userinfo)
+		*u2.User = *u.User
+	}
+	return u2
+}
+
+// CloneWithContext is like [Request.Clone], but the clone's context is ctx,
+// so middleware can pass a request carrying request-scoped values to the next
+// handler without changing the original request. The provided ctx must be non-nil.
+func (r *Request) CloneWithContext(ctx context.Context) *Request {
+	if ctx == nil {
+		panic("nil context")
+	}
+	clone := r.Clone()
+	clone.ctx = ctx
+	return clone
+}
+
+// Context returns the request's context. To change the context, use
+// [Request.Clone] or [Request.WithContext].
+//
+// The returned context is always non-nil; it defaults to the
+// background context.
+//
+// For incoming server requests, the context is canceled when the
+// client's connection closes or the ServeHTTP method returns.
+func (r *Request) Context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
+// Done returns a channel that's closed when the request's context is done.
+// It is shorthand for r.Context().Done().
+//
+// For incoming server requests, the channel is closed when the client
+// closes the connection, the connection's read deadline passes, or the
+// ServeHTTP method returns, so a handler can abort long work whose
+// result nobody will receive:
+//
+//	select {
+//	case res := <-work:
+//		// write res
+//	case <-r.Done():
+//		return
+//	}
+func (r *Request) Done() <-chan struct{} {
+	return r.Context().Done()
+}
+
+// Pattern returns the [Mux] pattern that matched the request, such as "/items/",
+// for logging or metrics. It returns "" if the request wasn't routed by a Mux
+// or no pattern matched it.
+func (r *Request) Pattern() string {
+	return r.pat
+}
+
+// BytesRead returns the number of bytes of a request received by a server
+// read so far: its request line and header, and the body bytes the handler
+// has read. Once the body is read to EOF, it is the size of the request.
+// For a chunked body, the chunk sizes and the trailer aren't counted.
+//
+// For client requests, BytesRead returns 0.
+func (r *Request) BytesRead() int64 {
+	if r.bytesRead == nil {
+		return 0
+	}
+	return *r.bytesRead
+}
+
+// WithContext returns a shallow copy of r with its context changed
+// to ctx. The provided ctx must be non-nil.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	if ctx == nil {
+		panic("nil context")
+	}
+	r2 := new(Request)
+	*r2 = *r
+	r2.ctx = ctx
+	return r2
+}
+
+// WithValue returns a shallow copy of r whose context carries val for key,
+// as set by [context.WithValue], so middleware can attach request-scoped data,
+// such as an authenticated user, for the handlers it calls:
+//
+//	next.ServeHTTP(w, http.WithValue(r, userKey{}, user))
+//
+// The same rules as for context keys apply: key must be comparable and
+// should be of an unexported type to avoid collisions between packages.
+func WithValue(r *Request, key, val any) *Request {
+	return r.WithContext(context.WithValue(r.Context(), key, val))
+}
+
+// Value returns the value associated with key in the context of r,
+// such as one attached by [WithValue], or nil if there is none.
+func Value(r *Request, key any) any {
+	return r.Context().Value(key)
+}
+
 // Reset resets the Request.
 func (p *Request) Reset() {
 	p = new(Request)
@@ -533,6 +829,16 @@ func (r *Request) Cookies() []*Cookie {
 	return readCookies(r.Header, "")
 }
 
+// CookiesNamed parses and returns the named HTTP cookies sent with the request
+// or an empty slice if none matched. Duplicate cookies are returned in the
+// order they were sent.
+func (r *Request) CookiesNamed(name string) []*Cookie {
+	if name == "" {
+		return []*Cookie{}
+	}
+	return readCookies(r.Header, name)
+}
+
 // AddCookie adds a cookie to the request. Per RFC 6265 section 5.4,
 // AddCookie does not attach more than one [Cookie] header field. That
 // means all cookies, if any, are written into the same line,
@@ -551,6 +857,96 @@ func (r *Request) AddCookie(c *Cookie) {
 	}
 }
 
+// SetQueryParam sets the query parameter key of r's URL to value, replacing
+// any existing values, and updates r.URL.RawQuery, e.g., to add a page number
+// before sending the request. The query is re-encoded with its keys sorted.
+// A Form that was already parsed isn't updated.
+func (r *Request) SetQueryParam(key, value string) {
+	q := r.URL.Query()
+	q.Set(key, value)
+	r.URL.RawQuery = q.Encode()
+}
+
+// DelQueryParam deletes the query parameter key of r's URL, like
+// [Request.SetQueryParam] sets one.
+func (r *Request) DelQueryParam(key string) {
+	q := r.URL.Query()
+	if !q.Has(key) {
+		return
+	}
+	q.Del(key)
+	r.URL.RawQuery = q.Encode()
+}
+
+// maxJSONBodySize is the size of the largest body [Request.BindJSON] reads,
+// unless the body is limited by [MaxBytesReader].
+const maxJSONBodySize = 10 << 20 // 10 MB
+
+// BindJSON reads the JSON-encoded request body and stores the result in the
+// value pointed to by `v`, for handlers accepting JSON.
+//
+// BindJSON returns [ErrNotJSON] if the Content-Type of the request is not
+// JSON, e.g., "application/json", without reading the body. Bodies larger than
+// 10 MB aren't read, and a [*MaxBytesError] is returned; use [MaxBytesReader]
+// for a different limit. A body that isn't valid JSON, including an empty one,
+// returns an error wrapping the error of [json.Unmarshal].
+func (r *Request) BindJSON(v any) error {
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		return ErrNotJSON
+	}
+	if r.Body == nil {
+		return errors.New("http: request has no body")
+	}
+
+	var body io.Reader = r.Body
+	limit := int64(1<<63 - 1)
+	if _, ok := r.Body.(*maxBytesReader); !ok {
+		limit = maxJSONBodySize
+		body = io.LimitReader(r.Body, limit+1)
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(b)) > limit {
+		return &MaxBytesError{Limit: limit}
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("http: malformed JSON body: %w", err)
+	}
+	return nil
+}
+
+// BasicAuth returns the username and password provided in the request's
+// Authorization header, if the request uses HTTP Basic Authentication.
+// See RFC 7617, section 2.
+func (r *Request) BasicAuth() (username, password string, ok bool) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return "", "", false
+	}
+	return parseBasicAuth(auth)
+}
+
+// parseBasicAuth parses an HTTP Basic Authentication string.
+// "Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==" returns ("Aladdin", "open sesame", true).
+func parseBasicAuth(auth string) (username, password string, ok bool) {
+	const prefix = "Basic "
+	// Case insensitive prefix match. See RFC 7617, section 2.
+	if len(auth) < len(prefix) || !ascii.EqualFold(auth[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	c, err := base64.StdEncoding.DecodeString(auth[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+	username, password, ok = strings.Cut(string(c), ":")
+	if !ok {
+		return "", "", false
+	}
+	return username, password, true
+}
+
 func (r *Request) FormValue(key string) string {
 	if r.Form == nil {
 		// TODO: Implement parseForm
@@ -608,6 +1004,66 @@ func (r *Request) Head() ([]byte, error) {
 	return []byte(s), nil
 }
 
+// Sign computes an HMAC-SHA256 with `key` over the canonical head of the request and sets
+// the base64 encoded result as the request's "Signature" header, which is also returned.
+//
+// The canonical head is the request line followed by the `headers` in the order given,
+// each in wire format. "Host" signs the request's Host, which isn't kept in the Header
+// map of a request read by a server. If any other of the headers isn't present, Sign
+// returns an error wrapping [ErrMissingSignedHeader] and the request isn't signed.
+func (r *Request) Sign(key []byte, headers []string) (string, error) {
+	mac, err := r.signature(key, headers)
+	if err != nil {
+		return "", err
+	}
+	sig := base64.StdEncoding.EncodeToString(mac)
+	r.Header.Set("Signature", sig)
+	return sig, nil
+}
+
+// VerifySignature reports whether the request's "Signature" header matches the
+// HMAC-SHA256 computed with `key` over the canonical head of the request.
+// It reports false if any of the headers isn't present. See [Request.Sign].
+func (r *Request) VerifySignature(key []byte, headers []string) bool {
+	sig, err := base64.StdEncoding.DecodeString(r.Header.Get("Signature"))
+	if err != nil || len(sig) == 0 {
+		return false
+	}
+	mac, err := r.signature(key, headers)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(sig, mac)
+}
+
+// signature returns the HMAC-SHA256 of the canonical head of the request.
+func (r *Request) signature(key []byte, headers []string) ([]byte, error) {
+	signed := make(Header, len(headers))
+	for _, k := range headers {
+		k = textproto.CanonicalMIMEHeaderKey(k)
+		vv := r.Header[k]
+		if k == "Host" {
+			// Sign the host as Request.Write sends it.
+			host := r.Host
+			if host == "" && r.URL != nil {
+				host = r.URL.Host
+			}
+			vv = nil
+			if host = timeformat.RemoveZone(host); host != "" {
+				vv = []string{host}
+			}
+		}
+		if len(vv) == 0 {
+			return nil, fmt.Errorf("%w: %q", ErrMissingSignedHeader, k)
+		}
+		signed[k] = vv
+	}
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, r.RequestLine()+"\r\n")
+	HeaderWriteOrdered(mac, signed, headers)
+	return mac.Sum(nil), nil
+}
+
 // Merge r with other
 func (r *Request) Merge(other *Request) {
 	if r.Method == "" {
@@ -708,16 +1164,30 @@ func (r *Request) Debug() string {
 	return startLine + s + endLine
 }
 
-// Dump serializes the request to wire format (raw http request) for debugging
+// Dump serializes the request to wire format (raw http request) for debugging.
+// The body is buffered and restored, so it can still be read after calling Dump.
+//
+// If ContentLength is known, at most ContentLength bytes of the body are read,
+// so dumping a request read from a live connection doesn't block waiting for EOF.
+// The body is not closed.
 func (r *Request) Dump() string {
 	head, _ := r.Head()
+	if r.Body == nil || r.Body == NoBody {
+		return string(head)
+	}
 	buf := new(bytes.Buffer)
-	_, err := io.Copy(buf, r.Body)
+	var err error
+	if r.ContentLength > 0 {
+		_, err = io.CopyN(buf, r.Body, r.ContentLength)
+	} else {
+		_, err = io.Copy(buf, r.Body)
+	}
 	if err != nil {
 		if err != io.EOF {
 			return ""
 		}
 	}
+	r.Body = io.NopCloser(bytes.NewReader(buf.Bytes()))
 	return string(append(head, buf.Bytes()...))
 }
 
@@ -859,6 +1329,36 @@ func (r *Request) MultipartReader() (*multipart.Reader, error) {
 	return r.multipartReader(true)
 }
 
+// EachPart streams the parts of a multipart/form-data or multipart/mixed
+// request body to fn, in order, without buffering them, so handlers can
+// process large uploads. A part can only be read until fn returns; the rest
+// of it is skipped to read the next part.
+//
+// EachPart stops at the first error returned by fn or met reading the
+// body, and returns it. It returns nil once all parts are delivered.
+// Like [Request.MultipartReader], it can't be combined with
+// [Request.ParseMultipartForm].
+func (r *Request) EachPart(fn func(*multipart.Part) error) error {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(p)
+		p.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
 func (r *Request) multipartReader(allowMixed bool) (*multipart.Reader, error) {
 	v := r.Header.Get("Content-Type")
 	if v == "" {
@@ -875,9 +1375,43 @@ func (r *Request) multipartReader(allowMixed bool) (*multipart.Reader, error) {
 	if !ok {
 		return nil, ErrMissingBoundary
 	}
+	if !validBoundary(boundary) {
+		return nil, ErrInvalidBoundary
+	}
 	return multipart.NewReader(r.Body, boundary), nil
 }
 
+// randomBoundary returns a random multipart boundary of 60 hex digits,
+// which is valid and unlikely to occur in the parts it separates.
+func randomBoundary() string {
+	var buf [30]byte
+	_, err := io.ReadFull(rand.Reader, buf[:])
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf[:])
+}
+
+// validBoundary reports whether boundary is a valid multipart boundary:
+// 1 to 70 characters of the "bchars" of RFC 2046, section 5.1.1,
+// not ending in a space.
+func validBoundary(boundary string) bool {
+	if len(boundary) < 1 || len(boundary) > 70 || boundary[len(boundary)-1] == ' ' {
+		return false
+	}
+	for _, b := range []byte(boundary) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' {
+			continue
+		}
+		switch b {
+		case '\'', '(', ')', '+', '_', ',', '-', '.', '/', ':', '=', '?', ' ':
+			continue
+		}
+		return false
+	}
+	return true
+}
+
 // FormFile returns the first file for the provided form key.
 // FormFile calls [Request.ParseMultipartForm] and [Request.ParseForm] if necessary.
 func (r *Request) FormFile(key string) (multipart.File, *multipart.FileHeader, error) {
@@ -900,11 +1434,9 @@ func (r *Request) FormFile(key string) (multipart.File, *multipart.FileHeader, e
 }
 
 // parseContentType detects the content type in the first 512 bytes of data for the MIME type.
+// The sniffed type is only a guess, so it isn't added to the Header as if the client sent it.
 func (r *Request) parseContentType(b []byte) {
-	ct := SniffContentType(b)
-	// TODO: Finish parsing content type
-	r.Header.Set("Content-Type", ct)
-	r.ContentType = ct
+	r.ContentType = SniffContentType(b)
 }
 
 func (r *Request) wantsClose() bool {
@@ -914,6 +1446,94 @@ func (r *Request) wantsClose() bool {
 	return hasToken(r.Header.Get("Connection"), "close")
 }
 
+// Diff returns the differences between the method, URL, header, and body
+// of r and other, one line per differing field, such as
+//
+//	Method: "GET" != "POST"
+//
+// It returns nil if the requests are the same. Diff is meant for tests.
+// The bodies are read to be compared, and replaced with readers of the same bytes.
+func (r *Request) Diff(other *Request) []string {
+	if r == nil || other == nil {
+		if r != other {
+			return []string{fmt.Sprintf("Request: %p != %p", r, other)}
+		}
+		return nil
+	}
+	var diffs []string
+	if r.Method != other.Method {
+		diffs = append(diffs, fmt.Sprintf("Method: %q != %q", r.Method, other.Method))
+	}
+	if u1, u2 := urlString(r.URL), urlString(other.URL); u1 != u2 {
+		diffs = append(diffs, fmt.Sprintf("URL: %q != %q", u1, u2))
+	}
+	diffs = append(diffs, diffHeader(r.Header, other.Header)...)
+	diffs = append(diffs, diffBody(&r.Body, &other.Body)...)
+	return diffs
+}
+
+// urlString returns the string form of u, or "" if u is nil.
+func urlString(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+// ProtoAtLeast reports whether the HTTP protocol used
+// in the request is at least major.minor.
+func (r *Request) ProtoAtLeast(major, minor int) bool {
+	return r.ProtoMajor > major ||
+		r.ProtoMajor == major && r.ProtoMinor >= minor
+}
+
+// expectsContinue reports whether the request has an "Expect: 100-continue" header,
+// i.e., the client waits for a "100 Continue" response before sending the body.
+func (r *Request) expectsContinue() bool {
+	return hasToken(r.Header.Get("Expect"), "100-continue")
+}
+
+// expectsBody reports whether the request method is one that is sent with a body,
+// so an empty body should be announced with "Content-Length: 0".
+// outgoingLength reports the Content-Length of this outgoing (Client) request.
+// It maps 0 into -1 (unknown) when the Body is non-nil.
+func (r *Request) outgoingLength() int64 {
+	if r.ContentLength == 0 && r.Body != nil && r.Body != NoBody {
+		return -1
+	}
+	return r.ContentLength
+}
+
+func (r *Request) expectsBody() bool {
+	switch r.Method {
+	case "POST", "PUT", "PATCH":
+		return true
+	}
+	return false
+}
+
+// wantsHttp10KeepAlive reports whether the request is an HTTP/1.0 request
+// that opts in to keep-alive with a "Connection: keep-alive" header.
+func (r *Request) wantsHttp10KeepAlive() bool {
+	if r.ProtoMajor != 1 || r.ProtoMinor != 0 {
+		return false
+	}
+	return hasToken(r.Header.Get("Connection"), "keep-alive")
+}
+
+// wantsKeepAlive reports whether the connection should be kept alive after the request.
+// HTTP/1.1 requests are kept alive unless they ask to close,
+// while HTTP/1.0 requests must opt in with "Connection: keep-alive".
+func (r *Request) wantsKeepAlive() bool {
+	if r.wantsClose() {
+		return false
+	}
+	if r.ProtoMajor == 1 && r.ProtoMinor == 0 {
+		return r.wantsHttp10KeepAlive()
+	}
+	return true
+}
+
 func (r *Request) closeBody() error {
 	if r.Body == nil {
 		return nil
@@ -978,67 +1598,127 @@ func readRequest(r *bufio.Reader) (*Request, error) {
 	// 1. Read and parse first Line
 	// TODO: Validate method, path, and protocol and parse HTTP Version
 	line, err := r.ReadString('\n') // read first line
-	defer func() {                  // clean up function
-		if err == io.EOF {
+	if err != nil {
+		if err == io.EOF && line != "" {
 			err = io.ErrUnexpectedEOF
 		}
-	}()
-	if err != nil && err != io.EOF {
 		return nil, err
 	}
+	if line == http2PrefaceLine {
+		// The preface would otherwise be read as an HTTP/2.0 request
+		// followed by a malformed header.
+		if rest, err := r.Peek(len(http2PrefaceRest)); err == nil && string(rest) == http2PrefaceRest {
+			r.Discard(len(rest))
+			return nil, ErrHTTP2Preface
+		}
+	}
 	method, requestURI, prot, ok := parseRequestLine(line) // parse first line
 	if !ok {
-		return nil, fmt.Errorf("invalid request line")
-	}
+		return nil, badRequestError("invalid request line", fmt.Errorf("%w %q", ErrInvalidRequestLine, line))
+	}
+	// The request-target is either in origin-form ("/path"), absolute-form
+	// ("http://host/path", as sent to proxies), asterisk-form ("*"), or, for
+	// CONNECT requests, authority-form ("host:port").
+	// CONNECT requests are used two different ways, and neither uses a full URL:
+	// the standard use is to tunnel HTTPS through an HTTP proxy.
+	// It looks like "CONNECT www.google.com:443 HTTP/1.1", and the parameter is
+	// just the authority section of a URL. This information should go in req.URL.Host.
 	rawurl := requestURI
-	if !strings.Contains(rawurl, "://") { // add scheme if missing
+	justAuthority := method == "CONNECT" && !strings.HasPrefix(rawurl, "/")
+	if justAuthority {
 		rawurl = "http://" + rawurl
 	}
 	u, err := url.ParseRequestURI(rawurl) // parse uri
 	if err != nil {
-		return nil, err
+		return nil, badRequestError("invalid request target", fmt.Errorf("%w %q", ErrInvalidRequestLine, requestURI))
 	}
-	if prot != protocol {
-		return nil, fmt.Errorf("invalid protocol")
+	if justAuthority {
+		// Strip the bogus "http://" back off.
+		u.Scheme = ""
+	}
+	major, minor, ok := ParseHTTPVersion(prot)
+	if !ok {
+		return nil, badRequestError("malformed HTTP version", fmt.Errorf("%w %q", ErrUnsupportedProtocol, prot))
+	}
+	if major != 1 {
+		return nil, statusError{StatusHTTPVersionNotSupported, "unsupported HTTP version", fmt.Errorf("%w %q", ErrUnsupportedProtocol, prot)}
 	}
 
 	// 2. Read and parse headers
-	header := NewHeader()
-	for { // read each new line until a blank line ("\r\n") is reached.
-		line, err := r.ReadString('\n') // read line
-		if err != nil && err != io.EOF {
-			return nil, err
-		}
-		if line == "\r\n" || err == io.EOF { // headers are terminated by a blank line "\r\n"
-			break
-		}
-		parts := strings.SplitN(line, ":", 2) // parse line by splitting line into key and value
-		if len(parts) < 2 {
-			return nil, fmt.Errorf("invalid header line")
-		}
-		// remove leading and trailing whitespace from key and value
-		k := strings.TrimSpace(parts[0])
-		v := strings.TrimSpace(parts[1])
-		header.Set(k, v)
+	header, n, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(header["Host"]) > 1 {
+		// RFC 7230, section 5.4: a request with more than one Host header
+		// field is rejected, since the server and a proxy could each pick
+		// a different one.
+		return nil, badRequestError("too many Host headers", fmt.Errorf("%w %q", ErrInvalidHeaderLine, header["Host"]))
+	}
+	chunked, err := parseTransferEncoding(header, major, minor)
+	if err != nil {
+		return nil, err
+	}
+	if chunked {
+		// RFC 7230, section 3.3.3: Transfer-Encoding overrides Content-Length,
+		// which must be removed, so the message isn't framed two different ways
+		// by this server and any proxy in front of it (request smuggling).
+		header.Del("Content-Length")
+	}
+	contentLength, err := parseContentLength(header)
+	if err != nil {
+		return nil, badRequestError("invalid Content-Length", err)
 	}
 
 	// 3. Set Request
 	req := &Request{
 		Method:        method,
 		Proto:         prot,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
 		RequestURI:    requestURI,
 		URL:           u,
 		Host:          u.Host,
 		Header:        header,
-		ContentLength: getContentLength(header),
+		ContentLength: contentLength,
 		ContentType:   header.Get("Content-Type"),
-		Body:          io.NopCloser(r),
+		Body:          NoBody,
 		Form:          nil,
 		MultipartForm: nil,
 		RemoteAddress: "",
+		bytesRead:     new(int64),
 	}
+	*req.bytesRead = int64(len(line)) + n
 
-	// TODO: Sniff the content type (MIME type) from first 512 bytes of body?
+	// Frame the body by Transfer-Encoding or Content-Length so reads stop at the end of the request.
+	if chunked {
+		req.TransferEncoding = []string{"chunked"}
+		req.ContentLength = -1
+		req.Trailer, err = announcedTrailer(header)
+		if err != nil {
+			return nil, badRequestError("invalid Trailer header", err)
+		}
+		req.Body = &chunkedBody{src: internal.NewChunkedReader(r), r: r, trailer: req.Trailer}
+	} else if req.ContentLength > 0 {
+		req.Body = &fixedLengthBody{r: r, n: req.ContentLength}
+	}
+	if req.Body != NoBody {
+		req.Body = &countingBody{ReadCloser: req.Body, n: req.bytesRead}
+	}
+
+	// Sniff the content type (MIME type) from the first 512 bytes of the body if
+	// the client didn't send it. The bytes are peeked, so the handler still reads
+	// the whole body. Only bytes already buffered with the head are used, since
+	// waiting for more would block the request on a slow client or on one
+	// expecting "100 Continue" before sending the body. A chunked body can't
+	// be peeked unframed.
+	if req.ContentType == "" && req.ContentLength > 0 {
+		if n := int(min(req.ContentLength, sniffLen)); r.Buffered() >= n {
+			b, _ := r.Peek(n)
+			req.parseContentType(b)
+		}
+	}
 
 	// RFC 7230, section 5.3: Must treat
 	//	GET /index.html HTTP/1.1
@@ -1054,6 +1734,84 @@ func readRequest(r *bufio.Reader) (*Request, error) {
 	return req, nil
 }
 
+// parseTransferEncoding reports whether the body of a request with the given
+// header and HTTP version is chunked. Every Transfer-Encoding line is read, so
+// a coding can't be hidden from the server on a second line, and the only
+// coding supported is a single "chunked", which RFC 7230, section 3.3.3,
+// requires to be the final one. Any other coding is rejected with 501 (Not
+// Implemented) instead of framing the body by Content-Length.
+func parseTransferEncoding(header Header, major, minor int) (bool, error) {
+	raw, present := header["Transfer-Encoding"]
+	if !present {
+		return false, nil
+	}
+	if major == 1 && minor == 0 {
+		// HTTP/1.0 has no transfer codings.
+		return false, badRequestError("unsupported transfer encoding on HTTP/1.0", fmt.Errorf("%w %q", ErrInvalidHeaderLine, raw))
+	}
+	var codings []string
+	for _, v := range raw {
+		for _, c := range strings.Split(v, ",") {
+			if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+				codings = append(codings, c)
+			}
+		}
+	}
+	if len(codings) == 0 {
+		return false, badRequestError("empty Transfer-Encoding", fmt.Errorf("%w %q", ErrInvalidHeaderLine, raw))
+	}
+	if codings[len(codings)-1] != "chunked" {
+		return false, statusError{StatusNotImplemented, "unsupported transfer encoding", fmt.Errorf("%w %q", ErrInvalidHeaderLine, raw)}
+	}
+	if slices.Contains(codings[:len(codings)-1], "chunked") {
+		return false, badRequestError("chunked applied more than once", fmt.Errorf("%w %q", ErrInvalidHeaderLine, raw))
+	}
+	if len(codings) > 1 {
+		// Codings applied before chunked, such as gzip, aren't decoded.
+		return false, statusError{StatusNotImplemented, "unsupported transfer encoding", fmt.Errorf("%w %q", ErrInvalidHeaderLine, raw)}
+	}
+	return true, nil
+}
+
+// The HTTP/2 client connection preface (RFC 9113, section 3.4), split after its
+// first line, which looks like an HTTP/1.x request line.
+const (
+	http2PrefaceLine = "PRI * HTTP/2.0\r\n"
+	http2PrefaceRest = "\r\nSM\r\n\r\n"
+)
+
+// readHeader reads "<key>: <value>" lines from `r` until a blank line ("\r\n") or EOF is reached.
+// It returns the header and the number of bytes read.
+//
+// A line starting with a space or tab continues the previous header value (obsolete line folding).
+// As allowed by RFC 7230, section 3.2.4, such lines are rejected rather than unfolded.
+func readHeader(r *bufio.Reader) (Header, int64, error) {
+	header := NewHeader()
+	var n int64
+	for { // read each new line until a blank line ("\r\n") is reached.
+		line, err := r.ReadString('\n') // read line
+		if err != nil && err != io.EOF {
+			return nil, n, err
+		}
+		n += int64(len(line))
+		if line == "\r\n" || err == io.EOF { // headers are terminated by a blank line "\r\n"
+			break
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, n, badRequestError("obsolete line folding in header", fmt.Errorf("%w %q", ErrInvalidHeaderLine, line))
+		}
+		parts := strings.SplitN(line, ":", 2) // parse line by splitting line into key and value
+		if len(parts) < 2 {
+			return nil, n, badRequestError("invalid header line", fmt.Errorf("%w %q", ErrInvalidHeaderLine, line))
+		}
+		// remove leading and trailing whitespace from key and value
+		k := strings.TrimSpace(parts[0])
+		v := strings.TrimSpace(parts[1])
+		header.Add(k, v)
+	}
+	return header, n, nil
+}
+
 // func (r *Request) Cookie(name string) (*Cookie, error) {
 // 	if name == "" {
 // 		return nil, ErrNoCookie
@@ -70,12 +70,17 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/curol/network/http/internal"
 )
 
 var respExcludeHeader = map[string]bool{
@@ -102,23 +107,37 @@ type Response struct {
 
 	// 2.) Headers come after the response line and each header is a new line of format "<key>: <value>".
 	Header        Header
-	ContentLength int
+	ContentLength int64
 	ContentType   string
 
 	// 3.) Body is the payload or contents of the response.
 	// For brevity, only using []byte for body.
 	Body io.ReadCloser
 
+	// LenientJSON, if true, allows DecodeJSON to decode a body whose
+	// Content-Type is not JSON.
+	LenientJSON bool
+
 	// Request is the request that was sent to obtain this Response.
 	// Request's Body is nil (having already been consumed).
 	// This is only populated for Client requests.
 	Request *Request
 
+	// Uncompressed reports whether the response was sent compressed but
+	// was decompressed by the Client. The Content-Encoding and
+	// Content-Length headers are removed from such responses, and
+	// ContentLength is -1.
+	Uncompressed bool
+
 	// IsClose records whether the header directed that the connection be
 	// closed after reading Body. The value is advice for clients: neither
 	// ReadResponse nor Response.Write ever closes a connection.
 	IsClose bool
 
+	// bytesRead counts the bytes of a received response read so far.
+	// See BytesRead.
+	bytesRead *int64
+
 	conn net.Conn
 
 	wroteHeader bool
@@ -172,68 +191,151 @@ func (r *Response) Write(b []byte) (int, error) {
 	return r.conn.Write(b)
 }
 
+// WriteTo writes r in the HTTP/1.x wire format to w and returns the number
+// of bytes written.
+//
+// A *bufio.Writer passed in by the caller is owned by the caller, who is
+// responsible for flushing it. Any other writer, such as a net.Conn or an
+// *os.File, is wrapped in a *bufio.Writer that is flushed before returning.
 func (r *Response) WriteTo(w io.Writer) (int64, error) {
 	// Type switch writer
 	switch v := w.(type) {
 	case *bufio.Writer:
 		return r.write(v)
-	case *bytes.Buffer:
-		bw := bufio.NewWriter(v)
-		return r.write(bw)
 	default:
-		// TOOD: Add other types
+		bw := bufio.NewWriter(w)
+		n, err := r.write(bw)
+		if err != nil {
+			return n, err
+		}
+		return n, bw.Flush()
 	}
-	return 0, fmt.Errorf("invalid type")
 }
 
 // write serializes the response to the writer.
+// It returns the number of bytes written, including the head and any chunk framing.
 func (r *Response) write(w *bufio.Writer) (int64, error) {
 	if r == nil {
 		return 0, fmt.Errorf("response is nil")
 	}
+	// The length of an in-memory body is known even if ContentLength isn't set.
+	if r.ContentLength == 0 && r.Body != nil && r.Body != NoBody && !HeaderHas(r.Header, "Content-Length") {
+		if n, ok := knownBodyLen(r.Body); ok {
+			r.ContentLength = n
+			r.Header.Set("Content-Length", strconv.FormatInt(n, 10))
+		}
+	}
+	// A body of unknown length is chunked, or else ends when the connection is closed.
+	unknownLength := r.Body != nil && r.ContentLength < 0
+	if unknownLength {
+		if r.canChunk() {
+			r.Header.Set("Transfer-Encoding", "chunked")
+		} else {
+			r.Header.Set("Connection", "close")
+			r.IsClose = true
+		}
+	}
+
+	// 1-3. Head
+	n, err := r.writeHead(w)
+	if err != nil {
+		return n, err
+	}
+
+	// 5. Body
+	if f, ok := r.Body.(*os.File); ok && r.ContentLength > 0 {
+		var n2 int64
+		n2, err = r.writeFile(w, f)
+		n += n2
+	} else if unknownLength && r.canChunk() {
+		counter := &countingWriter{w: w}
+		cw := internal.NewChunkedWriter(counter)
+		_, err = io.Copy(cw, r.Body)
+		if err == nil {
+			err = cw.Close() // terminating chunk
+		}
+		if err == nil {
+			_, err = counter.Write([]byte("\r\n")) // empty trailer
+		}
+		n += counter.n
+	} else if unknownLength {
+		var n2 int64
+		n2, err = io.Copy(w, r.Body)
+		n += n2
+	} else if r.Body != nil {
+		var n2 int64
+		n2, err = io.CopyN(w, r.Body, r.ContentLength) // copy Body to writer
+		n += n2
+		// TODO: Flush?
+		// err = w.Flush() // flush the writer to the client connection
+		// if err != nil {
+		// 	return 0, err
+		// }
+	}
+	return n, err
+}
+
+// canChunk reports whether a body of unknown length can be sent with the chunked
+// transfer coding. HTTP/1.0 predates it, so the body of a response to an HTTP/1.0
+// request is delimited by closing the connection instead.
+func (r *Response) canChunk() bool {
+	return r.Request == nil || r.Request.ProtoAtLeast(1, 1)
+}
+
+// removeBody removes the body of a response whose status doesn't allow one,
+// and the headers framing it, which it must not have either.
+func (r *Response) removeBody() {
+	r.Header.Del("Content-Length")
+	r.Header.Del("Transfer-Encoding")
+	r.ContentLength = 0
+	r.Body = nil
+}
+
+// writeHead writes the response line, the header, and the blank line ending the head to w.
+// It returns the number of bytes written.
+func (r *Response) writeHead(w *bufio.Writer) (int64, error) {
 	// 1. Response line
-	rl := fmt.Sprintf("%s %s %s\r\n", r.Proto, strconv.Itoa(r.StatusCode), r.StatusText)
-	_, err := w.Write([]byte(rl))
+	cw := &countingWriter{w: w}
+	_, err := io.WriteString(cw, r.StatusLine()+"\r\n")
 	if err != nil {
-		return 0, err
+		return cw.n, err
 	}
 
 	err = w.Flush() // flush request line
 	if err != nil {
-		return 0, err
+		return cw.n, err
 	}
 
 	// 2. Header
-	err = r.Header.Write(w)
+	err = r.Header.Write(cw)
 	if err != nil {
-		return 0, err
+		return cw.n, err
 	}
 	err = w.Flush() // flush header
 	if err != nil {
-		return 0, err
+		return cw.n, err
 	}
 
 	// 3. End of head
-	fmt.Fprintf(w, "\r\n") // automatically flushes
-
-	// 5. Body
-	if r.Body != nil {
-		contLen := int64(r.ContentLength)
-		_, err = io.CopyN(w, r.Body, contLen) // copy Body to writer
-		if err != nil {
-			return 0, err
-		}
-		// TODO: Flush?
-		// err = w.Flush() // flush the writer to the client connection
-		// if err != nil {
-		// 	return 0, err
-		// }
-	}
+	_, err = io.WriteString(cw, "\r\n")
+	return cw.n, err
+}
 
+// writeFile copies ContentLength bytes of the file body f to w.
+//
+// The head is flushed first, so that w hands f to the ReadFrom method of the writer it wraps.
+// When that is the raw connection (e.g., a *net.TCPConn), the file is copied without passing
+// through user space (i.e., sendfile).
+func (r *Response) writeFile(w *bufio.Writer, f *os.File) (int64, error) {
+	err := w.Flush()
 	if err != nil {
 		return 0, err
 	}
-	return int64(w.Size()), err
+	n, err := io.Copy(w, io.LimitReader(f, r.ContentLength))
+	if err == nil && n < r.ContentLength {
+		err = io.EOF
+	}
+	return n, err
 }
 
 // Cookies parses and returns the cookies set in the Set-Cookie headers.
@@ -254,12 +356,35 @@ func (r *Response) Cookies() []*Cookie {
 
 // Text writes the string `s` to the response body and sets the content to `text/plain`.
 func (r *Response) Text(s string) {
-	ct := "text/plain"
-	cl := strconv.Itoa(len(s))
+	r.setContent("text/plain", []byte(s))
+}
+
+// setContent sets the response body to `body`, of the media type `ct`.
+// Both the Content-Length header and ContentLength are set, since the header
+// is sent as is, while ContentLength decides how much of the body is written.
+func (r *Response) setContent(ct string, body []byte) {
 	r.Header.Set("Content-Type", ct)
-	r.Header.Set("Content-Length", cl)
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	r.ContentLength = int64(len(body))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+}
 
-	r.Body = io.NopCloser(bytes.NewBufferString(s))
+// Error replies with the status `code` and the plain text error message `msg`,
+// like the [Error] function does for a [ResponseWriter].
+// The X-Content-Type-Options header is set to "nosniff", so clients don't
+// sniff the message as another content type.
+//
+// The response is complete afterwards: its status can't be changed by WriteHeader.
+func (r *Response) Error(msg string, code int) {
+	body := msg + "\n"
+	r.SetStatus(code)
+	r.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	r.Header.Set("X-Content-Type-Options", "nosniff")
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	r.ContentLength = int64(len(body))
+	r.Body = io.NopCloser(strings.NewReader(body))
+	r.wroteHeader = true
+	r.code = code
 }
 
 // JSON writes the JSON `v` to the response body and sets the content to `application/json`.
@@ -268,39 +393,87 @@ func (r *Response) JSON(v any) error {
 	if err != nil {
 		return err
 	}
+	r.setContent("application/json", result)
+	return nil
+}
+
+// JSONIndent is like [Response.JSON] but indents the JSON like [json.MarshalIndent],
+// with each element on a new line starting with `prefix` and one copy of `indent`
+// per level of nesting, e.g., for a response meant to be read by people.
+func (r *Response) JSONIndent(v any, prefix, indent string) error {
+	result, err := json.MarshalIndent(v, prefix, indent)
+	if err != nil {
+		return err
+	}
+	r.setContent("application/json", result)
+	return nil
+}
+
+// JSONStream sets the response body to the JSON encoding of `v`, like [Response.JSON],
+// but the JSON is encoded with a [json.Encoder] while the body is written, instead
+// of being buffered whole, so large values don't have to fit in memory twice.
+//
+// The length of the body isn't known in advance, so it is sent with the chunked
+// transfer coding (or, to an HTTP/1.0 client, until the connection is closed).
+// An error encoding `v` is returned by the body's Read method, aborting the
+// response midway. The body must be read to the end or closed, which stops the
+// encoding.
+func (r *Response) JSONStream(v any) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(json.NewEncoder(pw).Encode(v))
+	}()
 	r.Header.Set("Content-Type", "application/json")
-	r.Header.Set("Content-Length", strconv.Itoa(len(result)))
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	r.Body = pr
+}
+
+// DecodeJSON reads the JSON-encoded response body and stores the result in the value pointed to by `v`.
+//
+// At most ContentLength bytes are read when ContentLength is set, and the body is closed afterwards.
+// DecodeJSON returns [ErrNotJSON] if the Content-Type of the response is not JSON, unless LenientJSON is set.
+func (r *Response) DecodeJSON(v any) error {
+	if r.Body == nil {
+		return errors.New("http: response has no body")
+	}
+	defer r.Body.Close()
 
-	r.Body = io.NopCloser(bytes.NewBuffer(result))
+	if !r.LenientJSON && !isJSONContentType(r.Header.Get("Content-Type")) {
+		return ErrNotJSON
+	}
 
-	return nil
+	var body io.Reader = r.Body
+	if r.ContentLength > 0 {
+		body = io.LimitReader(r.Body, r.ContentLength)
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
 }
 
-func (r *Response) HTML(s string) {
-	ct := "text/html"
-	cl := strconv.Itoa(len(s))
-	r.Header.Set("Content-Type", ct)
-	r.Header.Set("Content-Length", cl)
+// isJSONContentType reports whether the media type of ct is
+// application/json or a structured syntax suffix of "+json".
+func isJSONContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
 
-	r.Body = io.NopCloser(bytes.NewBufferString(s))
+func (r *Response) HTML(s string) {
+	r.setContent("text/html", []byte(s))
 }
 
 func (r *Response) XML(s string) {
-	ct := "text/xml"
-	cl := strconv.Itoa(len(s))
-	r.Header.Set("Content-Type", ct)
-	r.Header.Set("Content-Length", cl)
-
-	r.Body = io.NopCloser(bytes.NewBufferString(s))
+	r.setContent("text/xml", []byte(s))
 }
 
 func (r *Response) JSONP(s string) {
-	ct := "application/javascript"
-	cl := strconv.Itoa(len(s))
-	r.Header.Set("Content-Type", ct)
-	r.Header.Set("Content-Length", cl)
-
-	r.Body = io.NopCloser(bytes.NewBufferString(s))
+	r.setContent("application/javascript", []byte(s))
 }
 
 func (r *Response) File(s string) {
@@ -316,6 +489,7 @@ func (r *Response) File(s string) {
 	cl := strconv.FormatInt(stat.Size(), 10) // Convert cl to a string
 	r.Header.Set("Content-Type", ct)
 	r.Header.Set("Content-Length", cl)
+	r.ContentLength = stat.Size()
 
 	// TODO: Use io.NopCloser()?
 	// r.body = io.NopCloser(f)
@@ -326,6 +500,84 @@ func (r *Response) File(s string) {
 // Status
 //######################################################################################################################
 
+// Redirect replies to the request with a redirect to url by setting the
+// Location header and status code of the response.
+//
+// The provided code should be in the 3xx range and is usually
+// [StatusMovedPermanently], [StatusFound] or [StatusSeeOther].
+// Redirect returns an error, leaving r unchanged, if code is not a 3xx status code.
+func (r *Response) Redirect(url string, code int) error {
+	if code < 300 || code > 399 {
+		return fmt.Errorf("http: invalid redirect code %d", code)
+	}
+	r.Header.Set("Location", url)
+	r.SetStatus(code)
+	return nil
+}
+
+// SetStatus sets the status code of the response along with its status text and status,
+// e.g., SetStatus(404) sets StatusCode to 404, StatusText to "Not Found", and Status to "404 Not Found".
+func (r *Response) SetStatus(code int) {
+	r.StatusCode = code
+	r.StatusText = StatusText(code)
+	r.Status = strconv.Itoa(code) + " " + r.StatusText
+}
+
+// StatusLine returns the response line without the trailing CRLF.
+//
+// Format: "<protocol> <status code> <status text>", e.g., "HTTP/1.1 200 OK".
+func (r *Response) StatusLine() string {
+	return r.Proto + " " + strconv.Itoa(r.StatusCode) + " " + r.StatusText
+}
+
+// RetryAfter returns how long to wait before retrying the request, as set by the
+// Retry-After header of, e.g., a 429 (Too Many Requests) or 503 (Service Unavailable)
+// response. The header is either a number of seconds or an HTTP date, which is
+// relative to the current time. A date in the past is a zero duration.
+//
+// The boolean is false if the header is missing or invalid.
+func (r *Response) RetryAfter() (time.Duration, bool) {
+	v := strings.TrimSpace(r.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(v, 10, 32); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(time.Until(t), 0), true
+}
+
+// Diff returns the differences between the status line, header, and body
+// of r and other, one line per differing field, such as
+//
+//	StatusLine: "HTTP/1.1 200 OK" != "HTTP/1.1 404 Not Found"
+//
+// The order of the header keys doesn't matter. It returns nil if the
+// responses are the same. Diff is meant for tests.
+// The bodies are read to be compared, and replaced with readers of the same bytes.
+func (r *Response) Diff(other *Response) []string {
+	if r == nil || other == nil {
+		if r != other {
+			return []string{fmt.Sprintf("Response: %p != %p", r, other)}
+		}
+		return nil
+	}
+	var diffs []string
+	if s1, s2 := r.StatusLine(), other.StatusLine(); s1 != s2 {
+		diffs = append(diffs, fmt.Sprintf("StatusLine: %q != %q", s1, s2))
+	}
+	diffs = append(diffs, diffHeader(r.Header, other.Header)...)
+	diffs = append(diffs, diffBody(&r.Body, &other.Body)...)
+	return diffs
+}
+
 // Ok indicates that the request is successful.
 func (r *Response) Ok() {
 	r.StatusCode = 200
@@ -362,6 +614,36 @@ func (r *Response) InternalServerError() {
 	r.StatusText = "Internal Server Error"
 }
 
+// MovedPermanently indicates that the target resource has been assigned a new permanent URI.
+func (r *Response) MovedPermanently() {
+	r.SetStatus(StatusMovedPermanently)
+}
+
+// Found indicates that the target resource resides temporarily under a different URI.
+func (r *Response) Found() {
+	r.SetStatus(StatusFound)
+}
+
+// NotModified indicates that a conditional GET or HEAD request has been received and the resource has not been modified.
+func (r *Response) NotModified() {
+	r.SetStatus(StatusNotModified)
+}
+
+// MethodNotAllowed indicates that the method received in the request-line is known by the origin server but not supported by the target resource.
+func (r *Response) MethodNotAllowed() {
+	r.SetStatus(StatusMethodNotAllowed)
+}
+
+// TooManyRequests indicates that the user has sent too many requests in a given amount of time.
+func (r *Response) TooManyRequests() {
+	r.SetStatus(StatusTooManyRequests)
+}
+
+// ServiceUnavailable indicates that the server is currently unable to handle the request due to a temporary overload or scheduled maintenance.
+func (r *Response) ServiceUnavailable() {
+	r.SetStatus(StatusServiceUnavailable)
+}
+
 //********************************************************************************************************************
 // Getters
 //********************************************************************************************************************
@@ -373,6 +655,9 @@ func (r *Response) InternalServerError() {
 // ReadResponse reads and returns an HTTP response from r.
 // The req parameter optionally specifies the Request that corresponds
 // to this Response. If nil, a GET request is assumed.
+// If r is a *bufio.Reader, it is read from directly, so bytes it buffered
+// past the response are left in it for the caller. Otherwise, r is wrapped
+// in a new *bufio.Reader, which may read past the end of the response.
 // Clients must call resp.Body.Close when finished reading resp.Body.
 // After that call, clients can inspect resp.Trailer to find key/value
 // pairs included in the response trailer.
@@ -387,9 +672,18 @@ func ReadResponse(r io.Reader) (*Response, error) {
 
 // readResponse parses the response from the reader and return a Response.
 func readResponse(r io.Reader) (*Response, error) {
-	resp := &Response{}
-	reader := bufio.NewReader(r) // reader to read response
-	n := 0                       // number of bytes read
+	resp := &Response{bytesRead: new(int64)}
+	n := 0 // number of bytes read
+
+	// Reuse the caller's reader if it's already buffered, so the bytes it
+	// buffered aren't moved into a second buffer the caller can't reach.
+	var reader *bufio.Reader
+	switch v := r.(type) {
+	case *bufio.Reader:
+		reader = v
+	default:
+		reader = bufio.NewReader(r)
+	}
 
 	// 1.) Response line
 	statusLine, err := reader.ReadBytes('\n')
@@ -399,7 +693,9 @@ func readResponse(r io.Reader) (*Response, error) {
 	}
 	status := strings.TrimSpace(string(statusLine))
 	statusLines := strings.SplitN(status, " ", 3)
-	if len(statusLines) != 3 {
+	// RFC 9112, section 4: the reason phrase may be empty, and the space
+	// before it is trimmed with the line ending above.
+	if len(statusLines) < 2 {
 		return nil, fmt.Errorf("invalid response status line: %s", status)
 	}
 	resp.Proto = strings.TrimSpace(statusLines[0])
@@ -413,7 +709,9 @@ func readResponse(r io.Reader) (*Response, error) {
 	if err != nil {
 		return nil, err
 	}
-	resp.StatusText = strings.TrimSpace(statusLines[2])
+	if len(statusLines) == 3 {
+		resp.StatusText = strings.TrimSpace(statusLines[2])
+	}
 
 	// 2.) Headers
 	resp.Header = NewHeader()
@@ -441,7 +739,18 @@ func readResponse(r io.Reader) (*Response, error) {
 
 	// TODO: 3.) Body
 	cl := resp.Header.Get("Content-Length")
-	if cl != "" {
+	if !bodyAllowedForStatus(resp.StatusCode) {
+		// RFC 7230, section 3.3.3: 1xx, 204, and 304 responses end with
+		// the head, whatever their framing headers say.
+		resp.Body = NoBody
+	} else if hasToken(resp.Header.Get("Transfer-Encoding"), "chunked") {
+		// RFC 7230, section 3.3.3: Transfer-Encoding overrides Content-Length,
+		// which is removed so the body isn't framed two different ways.
+		// The body is streamed in chunks until the terminating chunk.
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Body = &chunkedBody{src: internal.NewChunkedReader(reader), r: reader}
+	} else if cl != "" {
 		// if err != nil {
 		// 	return resp, fmt.Errorf("Error parsing 'Content-Length': %s", err)
 		// }
@@ -457,15 +766,43 @@ func readResponse(r io.Reader) (*Response, error) {
 		// }
 		// resp.body = buf
 		// resp.size = n + n2
-		resp.ContentLength, err = strconv.Atoi(cl)
+		resp.ContentLength, err = strconv.ParseInt(cl, 10, 64)
+		if err == nil && resp.ContentLength < 0 {
+			err = fmt.Errorf("negative length %d", resp.ContentLength)
+		}
 		if err != nil {
 			return resp, fmt.Errorf("Error parsing 'Content-Length': %s", err)
 		}
+		// Frame the body by Content-Length so reads stop at the end of the response.
+		resp.Body = io.NopCloser(io.LimitReader(reader, resp.ContentLength))
+	} else {
+		// RFC 7230, section 3.3.3: without framing headers, the body
+		// ends when the server closes the connection.
+		resp.ContentLength = -1
+		resp.IsClose = true
 		resp.Body = io.NopCloser(reader)
 	}
+	*resp.bytesRead = int64(n)
+	if resp.Body != nil && resp.Body != NoBody {
+		resp.Body = &countingBody{ReadCloser: resp.Body, n: resp.bytesRead}
+	}
 	return resp, nil
 }
 
+// BytesRead returns the number of bytes of a response received by a client
+// read so far: its status line and header, and the body bytes read from
+// Body. Once the body is read to EOF, it is the size of the response.
+// For a chunked body, the chunk sizes and the trailer aren't counted, and
+// for a decompressed body, the compressed bytes are counted.
+//
+// For responses written by a server, BytesRead returns 0.
+func (r *Response) BytesRead() int64 {
+	if r.bytesRead == nil {
+		return 0
+	}
+	return *r.bytesRead
+}
+
 type parsedResponseLine struct {
 	Version      string
 	StatusCode   int
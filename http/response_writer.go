@@ -5,6 +5,9 @@ import (
 	"bytes"
 	"io"
 	"net"
+	"strconv"
+
+	"github.com/curol/network/http/internal"
 )
 
 // A ResponseWriter interface is used by an HTTP handler to
@@ -78,26 +81,77 @@ type ResponseWriter interface {
 	WriteHeader(statusCode int)
 }
 
+// The Flusher interface is implemented by ResponseWriters that allow
+// an HTTP handler to flush buffered data to the client.
+//
+// The default [ResponseWriter] implementation supports [Flusher],
+// but ResponseWriter wrappers may not. Handlers
+// should always test for this ability at runtime.
+//
+// Note that even for ResponseWriters that support Flush,
+// if the client is connected through an HTTP proxy,
+// the buffered data may not reach the client until the response
+// completes.
+type Flusher interface {
+	// Flush sends any buffered data to the client.
+	Flush()
+}
+
+// bufferBeforeFlushSize is the size of the body data a responseWriter buffers
+// before it sends the head and streams the rest of the body.
+const bufferBeforeFlushSize = 4 << 10
+
 // responseWriter is the default implementation of [ResponseWriter] for the server.
 // Moreover, responseWriter is just a wrapper around [Response] and [serverConn].
+//
+// The data written by the handler is buffered and sent with a Content-Length once the handler returns,
+// unless the handler calls Flush or writes more than bufferBeforeFlushSize bytes. Then, the head is
+// sent right away and the body is streamed: with the Content-Length the handler set, if any, or else
+// in chunks (or, for HTTP/1.0, until the connection is closed).
 type responseWriter struct {
 	conn net.Conn
 	res  *Response
 	req  *Request
 	buf  *bytes.Buffer
+	r    *bufio.Reader // connection reader, handed over by Hijack
+	cr   *connReader   // reader of the connection under r, whose background read Hijack stops
+	w    *bufio.Writer // connection writer, for flushing before the handler returns
+
+	hijacked bool  // the handler took over the connection with Hijack
+	headLen  int64 // length of the body written in response to a HEAD request, which is discarded
+
+	wroteHead  bool           // the head was flushed to w
+	written    int64          // body bytes the handler wrote
+	cw         io.WriteCloser // chunked writer of the body, if the head was flushed without a Content-Length
+	closeAfter bool           // the body is delimited by closing the connection
+	err        error          // first error writing to w
 }
 
-func newResponseWriter(conn net.Conn, req *Request) *responseWriter {
+func newResponseWriter(conn net.Conn, r *bufio.Reader, w *bufio.Writer, req *Request) *responseWriter {
+	res := NewResponse(conn)
+	res.Request = req
 	return &responseWriter{
 		conn: conn,
-		res:  NewResponse(conn),
+		res:  res,
 		req:  req,
 		buf:  bytes.NewBuffer(nil),
+		r:    r,
+		w:    w,
 	}
 }
 
+// Hijack lets the handler take over the connection. See [Hijacker].
+// The returned reader and writer are the ones the server used for the connection,
+// so the reader may hold data the client sent after the request.
 func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	return rw.conn, bufio.NewReadWriter(bufio.NewReader(rw.conn), bufio.NewWriter(rw.conn)), nil
+	if rw.hijacked {
+		return nil, nil, ErrHijacked
+	}
+	rw.hijacked = true
+	if rw.cr != nil {
+		rw.cr.abortPendingRead()
+	}
+	return rw.conn, bufio.NewReadWriter(rw.r, rw.w), nil
 }
 
 func (rw *responseWriter) Header() Header {
@@ -105,7 +159,104 @@ func (rw *responseWriter) Header() Header {
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
-	return rw.buf.Write(b)
+	return rw.write(b, "")
+}
+
+// WriteString writes s like Write, without converting it to a []byte first.
+func (rw *responseWriter) WriteString(s string) (int, error) {
+	return rw.write(nil, s)
+}
+
+// write writes the body data of Write and WriteString, which is p if it's
+// non-nil, or else s, so the checks are the same for both.
+func (rw *responseWriter) write(p []byte, s string) (int, error) {
+	if rw.hijacked {
+		return 0, ErrHijacked
+	}
+	if !rw.bodyAllowed() {
+		return 0, ErrBodyNotAllowed
+	}
+	n := len(s)
+	if p != nil {
+		n = len(p)
+	}
+	if rw.req.Method == "HEAD" {
+		rw.headLen += int64(n)
+		return n, nil
+	}
+	if cl := rw.declaredLength(); cl >= 0 && rw.written+int64(n) > cl {
+		return 0, ErrContentLength
+	}
+	rw.written += int64(n)
+	if p != nil {
+		rw.buf.Write(p)
+	} else {
+		rw.buf.WriteString(s)
+	}
+	if rw.buf.Len() >= bufferBeforeFlushSize {
+		// Stream large bodies instead of holding them in memory.
+		if !rw.wroteHead {
+			rw.err = rw.writeHead()
+		}
+		if rw.err == nil {
+			rw.err = rw.writeBuffered()
+		}
+		if rw.err != nil {
+			return 0, rw.err
+		}
+	}
+	return n, nil
+}
+
+// declaredLength returns the length of the body given by the Content-Length
+// of the head, if it was sent, or else by the one the handler set, or -1 if
+// there's none.
+func (rw *responseWriter) declaredLength() int64 {
+	if rw.wroteHead {
+		if rw.fixedLength() {
+			return rw.res.ContentLength
+		}
+		return -1
+	}
+	if !HeaderHas(rw.res.Header, "Content-Length") {
+		return -1
+	}
+	n, err := parseContentLength(rw.res.Header)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// fixedLength reports whether the head was sent with a Content-Length,
+// so the body must be exactly that long.
+func (rw *responseWriter) fixedLength() bool {
+	return rw.wroteHead && rw.cw == nil && !rw.closeAfter && rw.res.ContentLength >= 0
+}
+
+// incomplete reports whether the head was sent with a Content-Length and
+// the handler wrote less, so the connection can't be reused.
+func (rw *responseWriter) incomplete() bool {
+	return rw.fixedLength() && rw.req.Method != "HEAD" && rw.written < rw.res.ContentLength
+}
+
+// bodyAllowed reports whether the status code the handler set allows a body.
+func (rw *responseWriter) bodyAllowed() bool {
+	return rw.res.code == 0 || bodyAllowedForStatus(rw.res.code)
+}
+
+// bodyAllowedForStatus reports whether a given response status code
+// permits a body. See RFC 7230, section 3.3.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == 204:
+		return false
+	case status == 304:
+		return false
+	}
+	return true
 }
 
 func (rw *responseWriter) WriteHeader(statusCode int) {
@@ -116,18 +267,158 @@ func (rw *responseWriter) Close() error {
 	return rw.conn.Close()
 }
 
+// Flush sends the head, if it wasn't sent yet, and the data written so far to the client.
+func (rw *responseWriter) Flush() {
+	if rw.err != nil || rw.hijacked {
+		return
+	}
+	if !rw.wroteHead {
+		rw.err = rw.writeHead()
+	}
+	if rw.err == nil {
+		rw.err = rw.writeBuffered()
+	}
+	if rw.err == nil {
+		rw.err = rw.w.Flush()
+	}
+}
+
+// writeHead writes the head of the response to rw.w before the body is complete.
+// Without a Content-Length, the body is chunked for HTTP/1.1 and
+// delimited by closing the connection for HTTP/1.0.
+func (rw *responseWriter) writeHead() error {
+	res := rw.res
+	if res.code != 0 {
+		res.SetStatus(res.code)
+	}
+	res.wroteHeader = true
+	rw.wroteHead = true
+	if !bodyAllowedForStatus(res.StatusCode) {
+		res.removeBody()
+	} else if HeaderHas(res.Header, "Content-Length") {
+		res.ContentLength = getContentLength(res.Header)
+	} else if rw.req.Method == "HEAD" {
+		res.ContentLength = -1 // no body follows
+	} else if res.canChunk() {
+		res.ContentLength = -1
+		res.Header.Set("Transfer-Encoding", "chunked")
+		rw.cw = internal.NewChunkedWriter(rw.w)
+	} else {
+		res.ContentLength = -1
+		res.IsClose = true
+		rw.closeAfter = true
+	}
+	// The server decides whether to keep the connection alive after the
+	// handler returns, too late for this head, so it's decided from the
+	// request and the response alone here.
+	rw.setConnectionHeader(rw.req.wantsKeepAlive() && !hasToken(res.Header.Get("Connection"), "close") && !rw.closeAfter)
+	_, err := res.writeHead(rw.w)
+	return err
+}
+
+// setConnectionHeader sets the Connection header of the response to tell the
+// client whether the connection stays open after the response: "close" if it
+// doesn't, and "keep-alive" if it does for an HTTP/1.0 client, which would
+// otherwise expect it to be closed. HTTP/1.1 connections are persistent by
+// default, so the header the handler set, if any, is left as is then.
+//
+// The "Upgrade" option is kept if the response has an Upgrade header, as
+// required by RFC 7230, section 6.7 (e.g., for 426 Upgrade Required).
+func (rw *responseWriter) setConnectionHeader(keepAlive bool) {
+	h := rw.res.Header
+	var v string
+	switch {
+	case !keepAlive:
+		v = "close"
+	case rw.req.wantsHttp10KeepAlive():
+		v = "keep-alive"
+	default:
+		return
+	}
+	if HeaderHas(h, "Upgrade") {
+		v = "Upgrade, " + v
+	}
+	h.Set("Connection", v)
+}
+
+// writeBuffered writes the data buffered since the last flush to rw.w, as a chunk if chunking.
+func (rw *responseWriter) writeBuffered() error {
+	if rw.buf.Len() == 0 {
+		return nil
+	}
+	var dst io.Writer = rw.w
+	if rw.cw != nil {
+		dst = rw.cw
+	}
+	// Pieces no larger than rw.w's buffer go through it, so the connection
+	// isn't written more than WriteBufferSize bytes at a time.
+	for rw.buf.Len() > 0 {
+		if _, err := dst.Write(rw.buf.Next(rw.w.Size())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish writes the rest of a response whose head was flushed, and flushes it.
+func (rw *responseWriter) finish() error {
+	if rw.err != nil {
+		return rw.err
+	}
+	err := rw.writeBuffered()
+	if err == nil && rw.cw != nil {
+		// Terminating chunk and empty trailer.
+		err = rw.cw.Close()
+		if err == nil {
+			_, err = rw.w.WriteString("\r\n")
+		}
+	}
+	if err == nil {
+		err = rw.w.Flush()
+	}
+	return err
+}
+
+// WriteTo writes the response to `w` and flushes it.
+// The body is the data written by the handler, if any, or else the body set on the response.
+// If the handler flushed the head, the rest of the response is written to the connection writer instead.
 func (rw *responseWriter) WriteTo(w io.Writer) (int64, error) {
-	rw.conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
-	return 0, nil
-	// res := rw.res
-	// body := rw.buf
-	// cl := rw.buf.Len()
-	// clstr := strconv.Itoa(cl)
+	if rw.wroteHead {
+		return 0, rw.finish()
+	}
+	res := rw.res
+	if res.code != 0 {
+		res.SetStatus(res.code)
+	}
+	if !bodyAllowedForStatus(res.StatusCode) {
+		res.removeBody()
+	} else if rw.req.Method == "HEAD" {
+		// Send the length of the body the handler wrote, or else the
+		// Content-Length it set, but not the body.
+		if rw.headLen > 0 || res.Body == nil && !HeaderHas(res.Header, "Content-Length") {
+			res.ContentLength = rw.headLen
+			res.Header.Set("Content-Length", strconv.FormatInt(res.ContentLength, 10))
+		} else if res.ContentLength == 0 {
+			res.ContentLength = getContentLength(res.Header)
+		}
+		res.Body = nil
+	} else if res.Body == nil || rw.buf.Len() > 0 {
+		res.ContentLength = int64(rw.buf.Len())
+		res.Header.Set("Content-Length", strconv.FormatInt(res.ContentLength, 10))
+		res.Body = io.NopCloser(rw.buf)
+	} else if res.ContentLength == 0 {
+		res.ContentLength = getContentLength(res.Header)
+	}
 
-	// res.ContentLength = cl
-	// res.Header.Set("Content-Length", clstr)
-	// res.Body = io.NopCloser(body)
-	// return res.WriteTo(w)
+	bw, ok := w.(*bufio.Writer)
+	if !ok {
+		bw = bufio.NewWriter(w)
+	}
+	n, err := res.WriteTo(bw)
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
 }
 
 func (rw *responseWriter) Text(s string) {
@@ -141,3 +432,7 @@ func (rw *responseWriter) HTML(s string) {
 func (rw *responseWriter) JSON(s string) {
 	rw.res.JSON(s)
 }
+
+func (rw *responseWriter) File(s string) {
+	rw.res.File(s)
+}
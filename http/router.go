@@ -2,6 +2,7 @@ package http
 
 import (
 	"fmt"
+	"strings"
 )
 
 // Router is a barbones router that maps requests to handlers.
@@ -32,6 +33,18 @@ func getHandler(r *Router, method string, path string) HandlerFunc {
 	return r.handlers[method+" "+path]
 }
 
+// Get the methods with a handler for path
+func allowedMethods(r *Router, path string) []string {
+	var methods []string
+	for key := range r.handlers {
+		method, p, _ := strings.Cut(key, " ")
+		if p == path && method != "NotFound" {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
 // Route request to handler
 func (r *Router) Route(req *Request, w ResponseWriter) {
 	fmt.Println("Router: Routing request", req)
@@ -40,6 +53,11 @@ func (r *Router) Route(req *Request, w ResponseWriter) {
 	// TODO: Which path to use req.URL or req.RequestURI?
 	handler := getHandler(r, req.Method, req.URL.Path)
 	if handler == nil {
+		// The path is routed for other methods only.
+		if allowed := allowedMethods(r, req.URL.Path); len(allowed) > 0 {
+			MethodNotAllowed(w, allowed)
+			return
+		}
 		// Not found
 		fmt.Println("Route not found.")
 		handler = getHandler(r, "NotFound", "/")
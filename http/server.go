@@ -2,21 +2,32 @@ package http
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Server is a simple HTTP server and architure without all the extra services.
 type Server struct {
 	// Connection
-	Network        string
-	Address        string
-	Deadline       time.Time
-	Logger         Log
-	Handler        Handler // handler to invoke, http.DefaultServeMux if nil
-	Listener       net.Listener
+	Network  string
+	Address  string
+	Deadline time.Time
+	Logger   Log
+	Handler  Handler // handler to invoke, http.DefaultServeMux if nil
+	Listener net.Listener
+	// MaxHeaderBytes controls the maximum number of bytes the
+	// server will read parsing the request header's keys and
+	// values, including the request line. It does not limit the
+	// size of the request body.
+	// If zero, DefaultMaxHeaderBytes is used.
 	MaxHeaderBytes int
 	// ReadTimeout is the maximum duration for reading the entire
 	// request, including the body. A zero or negative value means
@@ -49,7 +60,35 @@ type Server struct {
 	// zero, there is no timeout.
 	IdleTimeout time.Duration
 
+	// BaseContext optionally specifies a function that returns
+	// the base context for incoming requests on this server.
+	// The provided Listener is the specific Listener that's
+	// about to start accepting requests.
+	// If BaseContext is nil, the default is context.Background().
+	// If non-nil, it must return a non-nil context.
+	BaseContext func(net.Listener) context.Context
+
+	// ReadBufferSize is the size of the buffer used to read requests
+	// from a connection. If zero, the default size of [bufio.NewReader] is used.
+	ReadBufferSize int
+
+	// WriteBufferSize is the size of the buffer used to write responses
+	// to a connection. If zero, the default size of [bufio.NewWriter] is used.
+	WriteBufferSize int
+
+	// ValidateHost optionally reports whether the server is authoritative
+	// for host, the Host of a request (see [Request.Host]), which may include
+	// a port. Requests for other hosts are answered with 421 (Misdirected
+	// Request) without calling the Handler, which protects it from DNS
+	// rebinding attacks. If ValidateHost is nil, all hosts are served.
+	ValidateHost func(host string) bool
+
 	isShutdown bool
+
+	mu         sync.Mutex
+	activeConn map[net.Conn]bool // connections being served, and whether each is idle between requests
+	inShutdown bool              // no connection is kept alive once set
+	conns      sync.WaitGroup    // service goroutines of the connections
 }
 
 func NewServer(network string, address string) *Server {
@@ -63,7 +102,7 @@ func NewServer(network string, address string) *Server {
 		Handler:           NewMux(),
 		Deadline:          time.Now().Add(5 * time.Minute), // TODO: Set default deadlines
 		Listener:          nil,
-		MaxHeaderBytes:    1 << 20,         // 1 MB
+		MaxHeaderBytes:    DefaultMaxHeaderBytes,
 		ReadTimeout:       5 * time.Minute, // Fixed: Use time.Duration value
 		ReadHeaderTimeout: 5 * time.Minute, // Fixed: Use time.Duration value
 		WriteTimeout:      5 * time.Minute, // Fixed: Use time.Duration value
@@ -89,87 +128,577 @@ func (s *Server) listenAndServe() error {
 	if err != nil {
 		return err
 	}
-	s.Listener = listener
-	s.Logger.Info("Server listening on " + address)
+	s.logger().Info("Server listening on " + address)
 
 	// 2. Defer server shutdown
 	defer s.Shutdown()
 
-	// 3. Listen for new connections and serve
+	// 3. Serve connections
+	return s.Serve(listener)
+}
+
+// RunContext is like [Server.Run], but it shuts the server down when ctx is done.
+// The listener is closed right away, so no new connections are accepted, and
+// idle connections are closed. Connections serving a request finish it and are
+// closed then; RunContext waits for them before it returns.
+//
+// RunContext returns nil if the server was shut down by ctx.
+func (s *Server) RunContext(ctx context.Context) error {
+	listener, err := net.Listen(s.Network, s.Address)
+	if err != nil {
+		return err
+	}
+	s.logger().Info("Server listening on " + s.Address)
+
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+	err = s.Serve(listener)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	s.closeIdleConns()
+	s.conns.Wait()
+	return s.shutdown()
+}
+
+// Serve accepts incoming connections on the Listener l, creating a
+// new service goroutine for each. The service goroutines read requests and
+// then call s.Handler to reply to them.
+//
+// Serve returns nil once l is closed.
+func (s *Server) Serve(l net.Listener) error {
+	s.Listener = l
+
+	baseCtx := context.Background()
+	if s.BaseContext != nil {
+		baseCtx = s.BaseContext(l)
+		if baseCtx == nil {
+			panic("BaseContext returned a nil context")
+		}
+	}
+
+	// Listen for new connections and serve
 	for {
-		// 3.1. Acceept next connection
-		conn, err := listener.Accept()
+		// 1. Acceept next connection
+		conn, err := l.Accept()
 		if err != nil {
-			if strings.Contains(err.Error(), "use of closed network connection") {
+			if errors.Is(err, net.ErrClosed) {
 				// The error "use of closed network connection" typically occurs when you're trying to perform a network operation (like Accept, Read, Write, etc.) on a network connection that has already been closed.
 				// This can often happen in a server that's being shut down while it's in the middle of accepting new connections
 				break
 			} else {
 				e := fmt.Errorf("Error on listener.Accept(): " + err.Error())
-				s.Logger.Fatal(e)
+				s.logger().Fatal(e)
 				continue
 			}
 		}
-		// 3.2. Serve connection
-		go s.serve(conn)
+		// 2. Serve connection
+		s.trackConn(conn, true)
+		s.conns.Add(1)
+		go func() {
+			defer s.conns.Done()
+			defer s.trackConn(conn, false)
+			s.serve(baseCtx, conn)
+		}()
 	}
 
-	// 4. Finish
+	// 3. Finish
 	return nil
 }
 
 // serve serves a new connection and calls the [Handler].
 // Moreover, serve handles each new connection, reads requests, and then calls [Handler] to reply to them.
-func (s *Server) serve(conn net.Conn) {
+//
+// The connection is kept alive for further requests unless the client or handler asks to close it.
+// HTTP/1.1 connections are kept alive by default, while HTTP/1.0 connections
+// are only kept alive if the request has a "Connection: keep-alive" header.
+func (s *Server) serve(ctx context.Context, conn net.Conn) {
+	cr := &connReader{conn: conn}
+	br := newBufioReader(cr, s.ReadBufferSize)
+	cw := &checkConnErrorWriter{conn: conn}
+	bw := newBufioWriter(cw.writer(), s.WriteBufferSize)
+	hijacked := false
+
 	// 1. Defer closing connection
 	defer func() {
+		if hijacked {
+			return // the handler owns the connection and its buffers
+		}
+		putBufioWriter(bw)
+		putBufioReader(br)
 		err := conn.Close() // close connection
-		if err != nil {
-			// TODO: Handle error
-			panic("Error closing connection in 'Server.serve(conn)': " + err.Error())
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			s.logger().Warn("Error closing connection: " + err.Error())
 		}
 	}()
+	for {
+		// The connection is idle until a request is read; a server that is
+		// shutting down closes it then, and serves no more requests on it.
+		if !s.setIdle(conn, true) {
+			return
+		}
 
-	// 2. Set connection properties
-	err := conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
-	if err != nil {
-		panic(err)
+		// 2. Set connection properties
+		// A zero or negative timeout means no deadline.
+		var readDeadline, writeDeadline time.Time
+		if s.ReadTimeout > 0 {
+			readDeadline = time.Now().Add(s.ReadTimeout)
+		}
+		if s.WriteTimeout > 0 {
+			writeDeadline = time.Now().Add(s.WriteTimeout)
+		}
+		err := conn.SetReadDeadline(readDeadline)
+		if err != nil {
+			return // connection was closed
+		}
+		err = conn.SetWriteDeadline(writeDeadline)
+		if err != nil {
+			return
+		}
+
+		// 3. Read Request
+		cr.setReadLimit(s.initialReadLimitSize())
+		req, err := ReadRequest(br) // read request
+		if cr.hitReadLimit() {
+			// The header may have been cut short, so don't trust what was read.
+			err = statusError{StatusRequestHeaderFieldsTooLarge, "request header too large", err}
+		}
+		cr.setInfiniteReadLimit()
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				s.logger().Warn("Error reading request: " + err.Error())
+			}
+			var v statusError
+			if errors.Is(err, ErrHTTP2Preface) {
+				// An HTTP/2 client can't read an HTTP/1.x response, so tell
+				// it in HTTP/2 to retry the request over HTTP/1.1.
+				io.WriteString(conn, http2GoAwayHTTP11Required)
+			} else if errors.As(err, &v) {
+				// Tell the client why its request was rejected before closing the connection.
+				publicErr := fmt.Sprintf("%d %s: %s", v.code, StatusText(v.code), v.text)
+				io.WriteString(conn, "HTTP/1.1 "+publicErr+errorHeaders+publicErr)
+			}
+			return
+		}
+		s.setIdle(conn, false)
+		req.RemoteAddress = conn.RemoteAddr().String()
+		if !req.expectsContinue() && req.Header.Get("Expect") != "" {
+			// The only expectation the server can meet is "100-continue".
+			rw := newResponseWriter(conn, br, bw, req)
+			rw.WriteHeader(StatusExpectationFailed)
+			rw.Header().Set("Connection", "close")
+			rw.WriteTo(bw)
+			return
+		}
+		if s.ValidateHost != nil && !s.ValidateHost(req.Host) {
+			// The connection reached the wrong server, so it isn't reused.
+			rw := newResponseWriter(conn, br, bw, req)
+			Error(rw, "421 misdirected request", StatusMisdirectedRequest)
+			rw.Header().Set("Connection", "close")
+			rw.WriteTo(bw)
+			return
+		}
+		reqCtx, cancel := context.WithCancelCause(ctx)
+		req.ctx = reqCtx
+		cw.cancel = cancel
+		rawBody := req.Body
+		var body *contextBody
+		if req.Body != NoBody {
+			body = newContextBody(reqCtx, conn, req.Body)
+			req.Body = body
+		}
+
+		// 4. Log status
+		s.logger().Status(req.RemoteAddress, req.Method, req.RequestURI)
+
+		// 5. Create response writer
+		rw := newResponseWriter(conn, br, bw, req)
+		var ecr *expectContinueReader
+		if req.expectsContinue() && req.ProtoAtLeast(1, 1) && req.ContentLength != 0 {
+			// Reply "100 Continue" when the handler reads the body. A handler can
+			// reject the request by writing a header (e.g., 417) before reading it.
+			ecr = &expectContinueReader{readCloser: req.Body, w: bw, res: rw.res}
+			req.Body = ecr
+		}
+
+		rw.cr = cr
+		if body == nil && br.Buffered() == 0 {
+			// There's no body for the handler to read, so watch the connection
+			// to cancel the request context if the client goes away meanwhile.
+			cr.startBackgroundRead(cancel)
+		} else if body != nil {
+			// Watch the connection once the handler has read the whole body,
+			// unless the client already sent more (e.g., a pipelined request).
+			body.onEOF = func() {
+				if br.Buffered() == 0 {
+					cr.startBackgroundRead(cancel)
+				}
+			}
+		}
+
+		// 6. Serve handler
+		handler := s.Handler
+		if handler == nil {
+			handler = DefaultServeMux
+		}
+		handler.ServeHTTP(rw, req)
+		// If the context was done while the handler was running, the connection's
+		// read deadline has been set in the past and it can't be reused.
+		interrupted := body != nil && !body.stop()
+		if cr.abortPendingRead() != nil {
+			interrupted = true
+		}
+		if rw.hijacked {
+			cancel(nil)
+			hijacked = true
+			return
+		}
+
+		// 7. Finish reading the request body and set connection header
+		keepAlive := req.wantsKeepAlive() && !interrupted && !hasToken(rw.Header().Get("Connection"), "close") && !rw.closeAfter && !rw.incomplete() && !s.shuttingDown()
+		if ecr != nil && !ecr.wroteContinue {
+			// The client may or may not send the body it was never asked for,
+			// so the next request can't be found.
+			keepAlive = false
+		}
+		if keepAlive && !discardBody(rawBody) {
+			// The next request can't be found cheaply, so close the connection.
+			keepAlive = false
+		}
+		rawBody.Close()
+		rw.setConnectionHeader(keepAlive)
+
+		// 8. Write response
+		// The context is canceled once the response is written, or with the write error
+		// as its cause if the client went away (e.g., "broken pipe").
+		_, err = rw.WriteTo(bw)
+		cancel(nil)
+		cw.cancel = nil
+		if err != nil {
+			s.logger().Warn("Error writing response to connection: " + err.Error())
+			return
+		}
+
+		// 9. Finish request
+		if !keepAlive {
+			return
+		}
 	}
-	err = conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
-	if err != nil {
-		panic(err)
+}
+
+// trackConn adds c to the connections being served, or removes it.
+func (s *Server) trackConn(c net.Conn, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.activeConn == nil {
+		s.activeConn = make(map[net.Conn]bool)
 	}
+	if add {
+		s.activeConn[c] = false
+	} else {
+		delete(s.activeConn, c)
+	}
+}
 
-	// 3. Read Request
-	req, err := ReadRequest(bufio.NewReader(conn)) // read request
-	if err != nil {
-		// TODO: Handle error
-		s.Logger.Fatal(err)
+// setIdle records whether c is idle between requests. It reports false if
+// c is becoming idle while the server is shutting down, so c must be closed.
+func (s *Server) setIdle(c net.Conn, idle bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idle && s.inShutdown {
+		return false
+	}
+	if _, ok := s.activeConn[c]; ok {
+		s.activeConn[c] = idle
 	}
+	return true
+}
 
-	// 4. Log status
-	s.Logger.Status(conn.RemoteAddr().String(), req.Method, req.RequestURI)
+// closeIdleConns starts shutting the server down: it closes the connections
+// that are idle, and those serving a request are closed once they finish it.
+func (s *Server) closeIdleConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inShutdown = true
+	for c, idle := range s.activeConn {
+		if idle {
+			c.Close()
+		}
+	}
+}
 
-	// 5. Create response writer
-	rw := newResponseWriter(conn, req)
+// shuttingDown reports whether the server is shutting down.
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inShutdown
+}
 
-	// 6. Serve handler
-	s.Handler.ServeHTTP(rw, req)
+// http2GoAwayHTTP11Required is sent to a client starting an HTTP/2 connection:
+// an empty SETTINGS frame, the server connection preface, followed by a GOAWAY
+// frame with the error code HTTP_1_1_REQUIRED (RFC 9113, sections 3.4, 6.8,
+// and 7), so the client falls back to HTTP/1.1 rather than failing obscurely.
+const http2GoAwayHTTP11Required = "\x00\x00\x00\x04\x00\x00\x00\x00\x00" + // SETTINGS, no settings
+	"\x00\x00\x08\x07\x00\x00\x00\x00\x00" + // GOAWAY, 8-byte payload
+	"\x00\x00\x00\x00\x00\x00\x00\x0d" // last stream ID 0, HTTP_1_1_REQUIRED
+
+// errorHeaders are the headers of the plain-text response sent for a request
+// that couldn't be read, up to and including the blank line before its body.
+const errorHeaders = "\r\nContent-Type: text/plain; charset=utf-8\r\nConnection: close\r\n\r\n"
+
+// maxPostHandlerReadBytes is the max number of Request.Body bytes not
+// consumed by a handler that the server will read from the client
+// in order to keep a connection alive. If there are more bytes than
+// this then the server to be paranoid instead sends a "Connection:
+// close" response.
+//
+// This number is approximately what a typical machine's TCP buffer
+// size is anyway.  (if we have the bytes on the machine, we might as
+// well read them)
+const maxPostHandlerReadBytes = 256 << 10
+
+// discardBody reads and discards what the handler left unread of body,
+// up to maxPostHandlerReadBytes. It reports whether the whole body was
+// consumed, so the next request on the connection starts at a clean boundary.
+func discardBody(body io.Reader) bool {
+	n, err := io.CopyN(io.Discard, body, maxPostHandlerReadBytes+1)
+	return err == io.EOF && n <= maxPostHandlerReadBytes
+}
 
-	// 7. Write response
-	_, err = rw.WriteTo(conn)
-	if err != nil {
-		s.Logger.Warn("Error writing response to connection: " + err.Error())
+// DefaultMaxHeaderBytes is the maximum permitted size of the headers
+// in an HTTP request.
+// This can be overridden by setting [Server.MaxHeaderBytes].
+const DefaultMaxHeaderBytes = 1 << 20 // 1 MB
+
+// defaultLogger is the logger of a Server whose Logger is nil.
+var defaultLogger Log = NewLogger()
+
+func (s *Server) logger() Log {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return defaultLogger
+}
+
+func (s *Server) maxHeaderBytes() int {
+	if s.MaxHeaderBytes > 0 {
+		return s.MaxHeaderBytes
+	}
+	return DefaultMaxHeaderBytes
+}
+
+// initialReadLimitSize is how much of the connection the server reads while
+// reading a request's head. It is a bit more than maxHeaderBytes to allow
+// for the bufio.Reader filling its buffer past the end of the head.
+func (s *Server) initialReadLimitSize() int64 {
+	return int64(s.maxHeaderBytes()) + 4096 // bufio slop
+}
+
+// connReader is the io.Reader wrapper of the connection read by the server.
+// It stops reading the connection once remain bytes were read, so a client
+// can't make the server read an endless request head.
+//
+// While a handler runs, connReader can read the connection in the background
+// to notice the client going away. See [connReader.startBackgroundRead].
+type connReader struct {
+	conn   net.Conn
+	remain int64 // bytes remaining
+
+	mu      sync.Mutex // guards the following
+	cond    *sync.Cond // signaled when the background read returns
+	inRead  bool       // a background read is in progress
+	aborted bool       // the background read was aborted by abortPendingRead
+	hasByte bool       // byteBuf holds a byte read in the background
+	byteBuf [1]byte
+	err     error // error of the background read, other than an abort
+}
+
+func (cr *connReader) setReadLimit(remain int64) { cr.remain = remain }
+func (cr *connReader) setInfiniteReadLimit()     { cr.remain = math.MaxInt64 }
+func (cr *connReader) hitReadLimit() bool        { return cr.remain <= 0 }
+
+// startBackgroundRead reads the connection in the background until the next
+// call to abortPendingRead. If the read fails, e.g., because the client closed
+// the connection or the read deadline passed, cancel is called with the error.
+// A byte the client sent meanwhile (e.g., a pipelined request) is kept for the next Read.
+func (cr *connReader) startBackgroundRead(cancel context.CancelCauseFunc) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.inRead {
+		panic("invalid concurrent background read")
+	}
+	if cr.hasByte {
+		return
+	}
+	if cr.cond == nil {
+		cr.cond = sync.NewCond(&cr.mu)
+	}
+	cr.inRead = true
+	go cr.backgroundRead(cancel)
+}
+
+func (cr *connReader) backgroundRead(cancel context.CancelCauseFunc) {
+	n, err := cr.conn.Read(cr.byteBuf[:])
+	cr.mu.Lock()
+	if n == 1 {
+		cr.hasByte = true
 	}
+	if ne, ok := err.(net.Error); ok && cr.aborted && ne.Timeout() {
+		// Ignore the timeout of abortPendingRead.
+	} else if err != nil {
+		cr.err = err
+		cancel(err)
+	}
+	cr.aborted = false
+	cr.inRead = false
+	cr.mu.Unlock()
+	cr.cond.Broadcast()
+}
+
+// abortPendingRead stops the background read, if any, and waits for it to return.
+// It returns the error the background read failed with, if any.
+func (cr *connReader) abortPendingRead() error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.inRead {
+		cr.aborted = true
+		cr.conn.SetReadDeadline(aLongTimeAgo)
+		for cr.inRead {
+			cr.cond.Wait()
+		}
+		cr.conn.SetReadDeadline(time.Time{})
+	}
+	return cr.err
+}
+
+func (cr *connReader) Read(p []byte) (n int, err error) {
+	if cr.hitReadLimit() {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if int64(len(p)) > cr.remain {
+		p = p[:cr.remain]
+	}
+	cr.mu.Lock()
+	if cr.inRead {
+		cr.mu.Unlock()
+		panic("invalid concurrent Read call")
+	}
+	if cr.hasByte {
+		p[0] = cr.byteBuf[0]
+		cr.hasByte = false
+		cr.mu.Unlock()
+		cr.remain--
+		return 1, nil
+	}
+	cr.mu.Unlock()
+	n, err = cr.conn.Read(p)
+	cr.remain -= int64(n)
+	return n, err
+}
 
-	// 8. Finish
-	// b := res.Bytes() // get response bytes
-	// _, err = res.WriteTo(b) // write response output to its writer `w`
-	// if err != nil {
-	// 	panic(err)
-	// }
+// checkConnErrorWriter writes to conn and records the first write error in werr,
+// canceling the context of the request being served, if any, with it.
+// Once the client has gone away, there's no point in the handler carrying on.
+type checkConnErrorWriter struct {
+	conn   net.Conn
+	werr   error
+	cancel context.CancelCauseFunc
+}
 
-	// TODO: Finish implementation
+func (w *checkConnErrorWriter) Write(p []byte) (n int, err error) {
+	n, err = w.conn.Write(p)
+	w.check(err)
+	return n, err
+}
+
+// writer returns w, or, if the connection implements [io.ReaderFrom], a writer
+// that also implements it, so a *net.TCPConn can still send files without
+// passing them through user space.
+func (w *checkConnErrorWriter) writer() io.Writer {
+	if _, ok := w.conn.(io.ReaderFrom); ok {
+		return checkConnErrorReaderFrom{w}
+	}
+	return w
+}
+
+// checkConnErrorReaderFrom is a checkConnErrorWriter for a connection that implements [io.ReaderFrom].
+type checkConnErrorReaderFrom struct {
+	*checkConnErrorWriter
+}
+
+func (w checkConnErrorReaderFrom) ReadFrom(r io.Reader) (n int64, err error) {
+	n, err = w.conn.(io.ReaderFrom).ReadFrom(r)
+	w.check(err)
+	return n, err
+}
+
+func (w *checkConnErrorWriter) check(err error) {
+	if err != nil && w.werr == nil {
+		w.werr = err
+		if w.cancel != nil {
+			w.cancel(err)
+		}
+	}
+}
+
+// defaultBufSize is the size of the pooled connection buffers, and the
+// default size of [bufio.NewReader] and [bufio.NewWriter].
+const defaultBufSize = 4096
+
+var (
+	bufioReaderPool sync.Pool
+	bufioWriterPool sync.Pool
+)
+
+// newBufioReader returns a reader of r with a buffer of size bytes,
+// reusing a pooled one when size is the default.
+func newBufioReader(r io.Reader, size int) *bufio.Reader {
+	if size > 0 && size != defaultBufSize {
+		return bufio.NewReaderSize(r, size)
+	}
+	if v := bufioReaderPool.Get(); v != nil {
+		br := v.(*bufio.Reader)
+		br.Reset(r)
+		return br
+	}
+	return bufio.NewReaderSize(r, defaultBufSize)
+}
+
+// putBufioReader resets br and returns it to the pool if it has the default size.
+func putBufioReader(br *bufio.Reader) {
+	if br.Size() != defaultBufSize {
+		return
+	}
+	br.Reset(nil)
+	bufioReaderPool.Put(br)
+}
+
+// newBufioWriter returns a writer to w with a buffer of size bytes,
+// reusing a pooled one when size is the default.
+func newBufioWriter(w io.Writer, size int) *bufio.Writer {
+	if size > 0 && size != defaultBufSize {
+		return bufio.NewWriterSize(w, size)
+	}
+	if v := bufioWriterPool.Get(); v != nil {
+		bw := v.(*bufio.Writer)
+		bw.Reset(w)
+		return bw
+	}
+	return bufio.NewWriterSize(w, defaultBufSize)
+}
+
+// putBufioWriter resets bw, discarding any unflushed data, and returns it
+// to the pool if it has the default size.
+func putBufioWriter(bw *bufio.Writer) {
+	if bw.Size() != defaultBufSize {
+		return
+	}
+	bw.Reset(nil)
+	bufioWriterPool.Put(bw)
 }
 
 // Shutdown gracefully shutsdown the server resources and cleans up.
@@ -179,16 +708,25 @@ func (s *Server) Shutdown() error {
 		return nil
 	}
 	time.Sleep(1 * time.Second) // wait for server to shutdown
-	err := s.clean()
+	err := s.shutdown()
 	if err != nil {
 		return err
 	}
+	time.Sleep(2 * time.Second) // wait for server to shutdown
+	return nil
+}
+
+// shutdown cleans up the server resources and marks the server as shut down.
+func (s *Server) shutdown() error {
+	err := s.clean()
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
 	// TODO: Add more cleanup
-	s.Logger.Info("Succesfully cleaned up server.")
+	s.logger().Info("Succesfully cleaned up server.")
 	// TODO: Implement graceful shutdown
-	s.Logger.Info("Successfuly shutdown server. Goodbye:)")
+	s.logger().Info("Successfuly shutdown server. Goodbye:)")
 	s.isShutdown = true
-	time.Sleep(2 * time.Second) // wait for server to shutdown
 	return nil
 }
 
@@ -219,6 +757,17 @@ func Error(w ResponseWriter, error string, code int) {
 	fmt.Fprintln(w, error)
 }
 
+// MethodNotAllowed replies to the request with an HTTP 405 method not allowed
+// error. The Allow header lists the methods the resource supports, sorted and
+// comma-separated, as required by RFC 9110, section 15.5.6.
+func MethodNotAllowed(w ResponseWriter, allowed []string) {
+	methods := slices.Clone(allowed)
+	slices.Sort(methods)
+	methods = slices.Compact(methods)
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	Error(w, StatusText(StatusMethodNotAllowed), StatusMethodNotAllowed)
+}
+
 // NotFound replies to the request with an HTTP 404 not found error.
 func NotFound(w ResponseWriter, r *Request) { Error(w, "404 page not found", StatusNotFound) }
 
@@ -270,7 +819,7 @@ func (s *Server) HandleFunc(pattern string, handler func(ResponseWriter, *Reques
 		s.Handler.(*Mux).HandleFunc(pattern, handler)
 	default:
 		//
-		s.Logger.Warn("Server handler is not defined...")
+		s.logger().Warn("Server handler is not defined...")
 		panic("server.HandleFunc type not found...")
 	}
 
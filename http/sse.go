@@ -0,0 +1,62 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// EventStream writes Server-Sent Events to a [ResponseWriter].
+//
+// Each event is flushed to the client as soon as it is sent.
+// See https://html.spec.whatwg.org/multipage/server-sent-events.html.
+type EventStream struct {
+	w ResponseWriter
+	f Flusher
+}
+
+// NewEventStream starts an event stream on w.
+//
+// It sets the "Content-Type: text/event-stream" header, disables caching and
+// buffering by proxies, and flushes the head to the client.
+// It returns [ErrNotSupported] if w doesn't implement [Flusher].
+func NewEventStream(w ResponseWriter) (*EventStream, error) {
+	f, ok := w.(Flusher)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // disables buffering by proxies, e.g., nginx
+	f.Flush()
+	return &EventStream{w: w, f: f}, nil
+}
+
+// lineEndReplacer turns the line endings recognized in event streams into LF.
+var lineEndReplacer = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// Send writes an event with the given name and data to the stream and flushes it.
+//
+// If event is empty, the "event:" line is omitted and clients see a "message" event.
+// Each line of data is written as its own "data:" line; CRLF, CR and LF
+// all end a line, as they do for clients.
+func (s *EventStream) Send(event, data string) error {
+	if strings.ContainsAny(event, "\r\n") {
+		return errors.New("http: invalid event name " + event)
+	}
+	var b strings.Builder
+	if event != "" {
+		b.WriteString("event: " + event + "\n")
+	}
+	data = lineEndReplacer.Replace(data)
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n") // a blank line dispatches the event
+	if _, err := io.WriteString(s.w, b.String()); err != nil {
+		return err
+	}
+	s.f.Flush()
+	return nil
+}
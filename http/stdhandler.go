@@ -0,0 +1,144 @@
+package http
+
+import (
+	nethttp "net/http"
+	neturl "net/url"
+
+	url "github.com/curol/network/url"
+)
+
+// FromStdHandler returns a [Handler] that serves requests with the net/http handler h,
+// so existing net/http handlers can be registered on a [Mux] or [Server].
+//
+// The request, its header, and its body are passed on to h, and the status code,
+// header, and body h writes are written to the [ResponseWriter].
+func FromStdHandler(h nethttp.Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		sr, err := toStdRequest(r)
+		if err != nil {
+			Error(w, err.Error(), StatusBadRequest)
+			return
+		}
+		h.ServeHTTP(stdResponseWriter{w}, sr)
+	})
+}
+
+// ToStdHandler returns a net/http handler that serves requests with h,
+// so h can be used with a net/http server. It is the reverse of [FromStdHandler].
+func ToStdHandler(h Handler) nethttp.Handler {
+	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, sr *nethttp.Request) {
+		r, err := fromStdRequest(sr)
+		if err != nil {
+			nethttp.Error(w, err.Error(), nethttp.StatusBadRequest)
+			return
+		}
+		h.ServeHTTP(fromStdResponseWriter{w}, r)
+	})
+}
+
+// toStdRequest returns r as a net/http request sharing its header, body, and context.
+func toStdRequest(r *Request) (*nethttp.Request, error) {
+	u := new(neturl.URL)
+	if r.URL != nil {
+		var err error
+		u, err = neturl.Parse(r.URL.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+	sr := &nethttp.Request{
+		Method:           r.Method,
+		URL:              u,
+		Proto:            r.Proto,
+		ProtoMajor:       r.ProtoMajor,
+		ProtoMinor:       r.ProtoMinor,
+		Header:           nethttp.Header(r.Header),
+		Body:             r.Body,
+		ContentLength:    r.ContentLength,
+		TransferEncoding: r.TransferEncoding,
+		Close:            r.Close,
+		Host:             r.Host,
+		Trailer:          nethttp.Header(r.Trailer),
+		RemoteAddr:       r.RemoteAddress,
+		RequestURI:       r.RequestURI,
+		TLS:              r.TLS,
+	}
+	if sr.Header == nil {
+		sr.Header = make(nethttp.Header)
+	}
+	if sr.Body == nil {
+		sr.Body = nethttp.NoBody
+	}
+	return sr.WithContext(r.Context()), nil
+}
+
+// fromStdRequest returns the net/http request sr as a Request sharing its header, body, and context.
+func fromStdRequest(sr *nethttp.Request) (*Request, error) {
+	u := new(url.URL)
+	if sr.URL != nil {
+		var err error
+		u, err = url.Parse(sr.URL.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+	r := &Request{
+		Method:           sr.Method,
+		URL:              u,
+		Proto:            sr.Proto,
+		ProtoMajor:       sr.ProtoMajor,
+		ProtoMinor:       sr.ProtoMinor,
+		Header:           Header(sr.Header),
+		Body:             sr.Body,
+		ContentLength:    sr.ContentLength,
+		ContentType:      sr.Header.Get("Content-Type"),
+		TransferEncoding: sr.TransferEncoding,
+		Close:            sr.Close,
+		Host:             sr.Host,
+		Trailer:          Header(sr.Trailer),
+		RemoteAddress:    sr.RemoteAddr,
+		RequestURI:       sr.RequestURI,
+		TLS:              sr.TLS,
+		ctx:              sr.Context(),
+	}
+	if r.Header == nil {
+		r.Header = NewHeader()
+	}
+	if r.Body == nil {
+		r.Body = NoBody
+	}
+	return r, nil
+}
+
+// stdResponseWriter is a net/http ResponseWriter writing to a ResponseWriter.
+type stdResponseWriter struct {
+	w ResponseWriter
+}
+
+func (w stdResponseWriter) Header() nethttp.Header      { return nethttp.Header(w.w.Header()) }
+func (w stdResponseWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
+func (w stdResponseWriter) WriteHeader(code int)        { w.w.WriteHeader(code) }
+
+// Flush flushes w if it implements Flusher, as net/http handlers test for
+// net/http.Flusher rather than Flusher.
+func (w stdResponseWriter) Flush() {
+	if f, ok := w.w.(Flusher); ok {
+		f.Flush()
+	}
+}
+
+// fromStdResponseWriter is a ResponseWriter writing to a net/http ResponseWriter.
+type fromStdResponseWriter struct {
+	w nethttp.ResponseWriter
+}
+
+func (w fromStdResponseWriter) Header() Header              { return Header(w.w.Header()) }
+func (w fromStdResponseWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
+func (w fromStdResponseWriter) WriteHeader(code int)        { w.w.WriteHeader(code) }
+
+// Flush flushes w if it implements net/http.Flusher.
+func (w fromStdResponseWriter) Flush() {
+	if f, ok := w.w.(nethttp.Flusher); ok {
+		f.Flush()
+	}
+}
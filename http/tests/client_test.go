@@ -2,14 +2,20 @@ package tests
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
 	http "github.com/curol/network/http"
 	"github.com/curol/network/http/tests/mock"
+	"github.com/curol/network/url"
 )
 
 func TestClient(t *testing.T) {
@@ -27,8 +33,12 @@ func TestClientGet(t *testing.T) {
 	// Arrange
 	// client := http.NewClient("GET", "www.google.com:80", nil, nil)
 	// Act
-	resp := http.Get("www.google.com:80", nil, nil)
+	resp, err := http.Get("www.google.com:80")
 	// Assert
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
 	if resp == nil {
 		t.Fatal("Response is nil")
 	}
@@ -108,3 +118,590 @@ func TestNetClient(t *testing.T) {
 	}
 	fmt.Println(response)
 }
+
+func TestClientMaxResponseBytes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	const size = 1 << 16
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		http.ReadRequest(bufio.NewReader(conn))
+		fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n", size)
+		conn.Write(bytes.Repeat([]byte("a"), size))
+	}()
+
+	client := http.NewClient("GET", ln.Addr().String(), nil, nil)
+	client.MaxResponseBytes = 1024
+	resp := client.Do()
+	if resp == nil || resp.Body == nil {
+		t.Fatal("Response body is nil")
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	var mbe *http.MaxBytesError
+	if !errors.As(err, &mbe) {
+		t.Fatalf("ReadAll error = %v; want *MaxBytesError", err)
+	}
+	if mbe.Limit != 1024 || len(b) != 1024 {
+		t.Errorf("read %d bytes with limit %d; want 1024 bytes with limit 1024", len(b), mbe.Limit)
+	}
+	var rtle *http.ResponseTooLargeError
+	if !errors.As(err, &rtle) {
+		t.Errorf("ReadAll error = %v; want *ResponseTooLargeError", err)
+	}
+}
+
+func TestClientCloseDelimitedBody(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			http.ReadRequest(bufio.NewReader(conn))
+			io.WriteString(conn, "HTTP/1.1 200 OK\r\nConnection: close\r\n\r\nhello")
+			conn.Close()
+		}
+	}()
+	addr := "http://" + ln.Addr().String() + "/"
+
+	resp, err := (&http.Client{}).Get(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "hello" {
+		t.Errorf("body = %q, err = %v; want %q read to the connection's close", body, err, "hello")
+	}
+	if resp.ContentLength != -1 {
+		t.Errorf("ContentLength = %d; want -1", resp.ContentLength)
+	}
+
+	// The body is still limited by MaxResponseBytes.
+	resp, err = (&http.Client{MaxResponseBytes: 3}).Get(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	var rtle *http.ResponseTooLargeError
+	if !errors.As(err, &rtle) || string(body) != "hel" {
+		t.Errorf("with MaxResponseBytes 3, body = %q, err = %v; want %q and *ResponseTooLargeError", body, err, "hel")
+	}
+}
+
+func TestClientDecompression(t *testing.T) {
+	const text = "hello, compressed world"
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	io.WriteString(gw, text)
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	io.WriteString(zw, text)
+	zw.Close()
+	encoded := map[string][]byte{"gzip": gz.Bytes(), "deflate": zl.Bytes()}
+
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		enc := r.URL.Query().Get("enc")
+		w.Header().Set("Content-Encoding", enc)
+		w.Write(encoded[enc])
+	})
+
+	for _, enc := range []string{"gzip", "deflate"} {
+		resp, err := (&http.Client{}).Get("http://" + addr + "/?enc=" + enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(body) != text {
+			t.Errorf("%s: body = %q, %v; want %q", enc, body, err, text)
+		}
+		if !resp.Uncompressed || resp.ContentLength != -1 {
+			t.Errorf("%s: Uncompressed = %v, ContentLength = %d; want true, -1", enc, resp.Uncompressed, resp.ContentLength)
+		}
+		if http.HeaderHas(resp.Header, "Content-Encoding") || http.HeaderHas(resp.Header, "Content-Length") {
+			t.Errorf("%s: header = %v; want no Content-Encoding and Content-Length", enc, resp.Header)
+		}
+
+		// With DisableCompression, the body is passed through as is.
+		resp, err = (&http.Client{DisableCompression: true}).Get("http://" + addr + "/?enc=" + enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !bytes.Equal(body, encoded[enc]) || resp.Uncompressed {
+			t.Errorf("%s: with DisableCompression, body = %q, Uncompressed = %v; want the encoded body", enc, body, resp.Uncompressed)
+		}
+		if got := resp.Header.Get("Content-Encoding"); got != enc {
+			t.Errorf("%s: with DisableCompression, Content-Encoding = %q; want %q", enc, got, enc)
+		}
+	}
+}
+
+func TestClientMaxResponseBytesDecompressed(t *testing.T) {
+	// 1 MB of zeros compresses to about 1 KB.
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(make([]byte, 1<<20))
+	gw.Close()
+	const limit = 4 << 10
+	if gz.Len() >= limit {
+		t.Fatalf("compressed body is %d bytes; want less than the limit %d", gz.Len(), limit)
+	}
+
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gz.Bytes())
+	})
+	resp, err := (&http.Client{MaxResponseBytes: limit}).Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	var rtle *http.ResponseTooLargeError
+	if !errors.As(err, &rtle) {
+		t.Errorf("ReadAll error = %v; want *ResponseTooLargeError", err)
+	}
+	if len(body) != limit {
+		t.Errorf("read %d decompressed bytes; want the limit %d", len(body), limit)
+	}
+}
+
+func TestClientAcceptEncoding(t *testing.T) {
+	const text = "hello, compressed world"
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			io.WriteString(w, text)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		io.WriteString(gw, text)
+		gw.Close()
+	})
+
+	// The client asks for gzip or deflate and decompresses the response.
+	req, err := http.NewRequest("GET", "http://"+addr+"/", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{}).Send(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Accept-Encoding"); got != "gzip, deflate" {
+		t.Errorf("server got Accept-Encoding %q; want %q", got, "gzip, deflate")
+	}
+	if string(body) != text || !resp.Uncompressed {
+		t.Errorf("body = %q, Uncompressed = %v; want %q, true", body, resp.Uncompressed, text)
+	}
+	if http.HeaderHas(req.Header, "Accept-Encoding") {
+		t.Error("Send added Accept-Encoding to the caller's request")
+	}
+
+	// A caller asking for gzip itself gets the compressed body.
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = (&http.Client{}).Send(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Uncompressed || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("with Accept-Encoding set by the caller: Uncompressed = %v, Content-Encoding = %q; want false, gzip", resp.Uncompressed, resp.Header.Get("Content-Encoding"))
+	}
+	if zr, err := gzip.NewReader(bytes.NewReader(body)); err != nil {
+		t.Errorf("with Accept-Encoding set by the caller: body isn't gzip: %v", err)
+	} else if b, _ := io.ReadAll(zr); string(b) != text {
+		t.Errorf("with Accept-Encoding set by the caller: decompressed body = %q; want %q", b, text)
+	}
+
+	// DisableCompression doesn't ask for compression.
+	resp, err = (&http.Client{DisableCompression: true}).Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Accept-Encoding"); got != "" || string(body) != text {
+		t.Errorf("with DisableCompression: server got Accept-Encoding %q, body = %q; want none, %q", got, body, text)
+	}
+}
+
+func TestDefaultClientGetPostHead(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	server := http.NewServer("tcp", ln.Addr().String())
+	server.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("Content-Type"), body)
+	})
+	go server.Serve(ln)
+	url := "http://" + ln.Addr().String() + "/echo"
+
+	readBody := func(resp *http.Response) string {
+		t.Helper()
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if resp.StatusCode != 200 || resp.Request == nil || resp.Request.Method != "GET" {
+		t.Errorf("Get: status %d, request %v", resp.StatusCode, resp.Request)
+	}
+	if got, want := readBody(resp), "GET  "; got != want {
+		t.Errorf("Get body = %q; want %q", got, want)
+	}
+
+	resp, err = http.Post(url, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	if got, want := readBody(resp), "POST text/plain hello"; got != want {
+		t.Errorf("Post body = %q; want %q", got, want)
+	}
+
+	// A reader of unknown length is sent chunked.
+	resp, err = http.Post(url, "text/plain", struct{ io.Reader }{strings.NewReader("hello")})
+	if err != nil {
+		t.Fatalf("Post with plain reader: %v", err)
+	}
+	if got, want := readBody(resp), "POST text/plain hello"; got != want {
+		t.Errorf("Post with plain reader body = %q; want %q", got, want)
+	}
+
+	resp, err = http.Head(url)
+	if err != nil {
+		t.Fatalf("Head: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("Head: status %d; want 200", resp.StatusCode)
+	}
+	if got := readBody(resp); got != "" {
+		t.Errorf("Head body = %q; want empty", got)
+	}
+
+	if _, err := http.Get("http://[::1"); err == nil {
+		t.Error("Get with malformed URL: got nil error")
+	}
+}
+
+func TestClientRedirectLocation(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	addr := ln.Addr().String()
+	server := http.NewServer("tcp", addr)
+	redirect := func(loc string) func(http.ResponseWriter, *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", loc)
+			w.WriteHeader(http.StatusFound)
+		}
+	}
+	server.HandleFunc("/absolute", redirect("http://"+addr+"/final"))
+	server.HandleFunc("/path-absolute", redirect("/final"))
+	server.HandleFunc("/scheme-relative", redirect("//"+addr+"/final"))
+	server.HandleFunc("/dir/relative", redirect("final"))
+	server.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "final")
+	})
+	server.HandleFunc("/dir/final", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "dir final")
+	})
+	go server.Serve(ln)
+
+	tests := []struct {
+		path    string
+		wantURL string
+		body    string
+	}{
+		{"/absolute", "http://" + addr + "/final", "final"},
+		{"/path-absolute", "http://" + addr + "/final", "final"},
+		{"/scheme-relative", "http://" + addr + "/final", "final"},
+		{"/dir/relative", "http://" + addr + "/dir/final", "dir final"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get("http://" + addr + tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != 200 || string(body) != tt.body {
+			t.Errorf("%s: got %d %q; want 200 %q", tt.path, resp.StatusCode, body, tt.body)
+		}
+		if got := resp.Request.URL.String(); got != tt.wantURL {
+			t.Errorf("%s: redirected to %q; want %q", tt.path, got, tt.wantURL)
+		}
+	}
+
+	// ErrUseLastResponse stops at the redirect.
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get("http://" + addr + "/path-absolute")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/final" {
+		t.Errorf("with ErrUseLastResponse got %d Location %q; want %d %q", resp.StatusCode, resp.Header.Get("Location"), http.StatusFound, "/final")
+	}
+}
+
+func TestClientRedirectCredentials(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	addr := ln.Addr().String()
+	_, port, _ := net.SplitHostPort(addr)
+	server := http.NewServer("tcp", addr)
+	server.HandleFunc("/same-host", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "http://"+addr+"/echo")
+		w.WriteHeader(http.StatusFound)
+	})
+	server.HandleFunc("/cross-host", func(w http.ResponseWriter, r *http.Request) {
+		// The same server, but under another host name.
+		w.Header().Set("Location", "http://localhost:"+port+"/echo")
+		w.WriteHeader(http.StatusFound)
+	})
+	server.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		for _, k := range []string{"Authorization", "Cookie", "Proxy-Authorization", "X-Other"} {
+			fmt.Fprintf(w, "%s=%s;", k, r.Header.Get(k))
+		}
+	})
+	go server.Serve(ln)
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/same-host", "Authorization=Basic dTpw;Cookie=session=1;Proxy-Authorization=Basic cDpw;X-Other=kept;"},
+		{"/cross-host", "Authorization=;Cookie=;Proxy-Authorization=;X-Other=kept;"},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", "http://"+addr+tt.path, map[string][]string{
+			"Authorization":       {"Basic dTpw"},
+			"Cookie":              {"session=1"},
+			"Proxy-Authorization": {"Basic cDpw"},
+			"X-Other":             {"kept"},
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Send(req)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != tt.body {
+			t.Errorf("%s: redirected request got %q; want %q", tt.path, body, tt.body)
+		}
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// The proxy records the request line and Proxy-Authorization it gets,
+	// answers requests itself, and refuses tunnels.
+	lines := make(chan string, 2)
+	auths := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			req, err := http.ReadRequest(bufio.NewReader(conn))
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			lines <- req.Method + " " + req.RequestURI
+			auths <- req.Header.Get("Proxy-Authorization")
+			if req.Method == "CONNECT" {
+				io.WriteString(conn, "HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n")
+			} else {
+				io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nproxy")
+			}
+			conn.Close()
+		}
+	}()
+
+	client := &http.Client{
+		Proxy: func(*http.Request) (*url.URL, error) {
+			return url.Parse("http://" + ln.Addr().String())
+		},
+	}
+	resp, err := client.Get("http://example.com/path?q=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "proxy" {
+		t.Errorf("body = %q; want %q", body, "proxy")
+	}
+	if got, want := <-lines, "GET http://example.com/path?q=1"; got != want {
+		t.Errorf("proxy got request line %q; want %q", got, want)
+	}
+
+	// HTTPS requests are tunneled with CONNECT, which this proxy refuses.
+	if _, err := client.Get("https://example.com/secret"); err == nil {
+		t.Error("Get through refused tunnel succeeded")
+	}
+	if got, want := <-lines, "CONNECT example.com:443"; got != want {
+		t.Errorf("proxy got request line %q; want %q", got, want)
+	}
+	<-auths
+	<-auths
+
+	// The user info of the proxy URL authenticates both kinds of request.
+	client.Proxy = func(*http.Request) (*url.URL, error) {
+		return url.Parse("http://user:pass@" + ln.Addr().String())
+	}
+	for _, u := range []string{"http://example.com/", "https://example.com/"} {
+		resp, err := client.Get(u)
+		if err == nil {
+			resp.Body.Close()
+		}
+		<-lines
+		if got, want := <-auths, "Basic dXNlcjpwYXNz"; got != want {
+			t.Errorf("%s: Proxy-Authorization = %q; want %q", u, got, want)
+		}
+	}
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		url  string
+		want string
+	}{
+		{nil, "http://example.com/", ""},
+		{map[string]string{"HTTP_PROXY": "http://proxy:8080"}, "http://example.com/", "http://proxy:8080"},
+		{map[string]string{"HTTP_PROXY": "proxy:8080"}, "http://example.com/", "http://proxy:8080"},
+		{map[string]string{"http_proxy": "http://lower:8080"}, "http://example.com/", "http://lower:8080"},
+		{map[string]string{"HTTP_PROXY": "http://proxy:8080"}, "https://example.com/", ""},
+		{map[string]string{"HTTPS_PROXY": "http://secure:8443"}, "https://example.com/", "http://secure:8443"},
+		{map[string]string{"HTTPS_PROXY": "http://secure:8443"}, "http://example.com/", ""},
+		{map[string]string{"HTTP_PROXY": "http://proxy:8080"}, "http://localhost/", ""},
+		{map[string]string{"HTTP_PROXY": "http://proxy:8080", "NO_PROXY": "example.com"}, "http://example.com/", ""},
+		{map[string]string{"HTTP_PROXY": "http://proxy:8080", "NO_PROXY": "example.com"}, "http://www.example.com/", ""},
+		{map[string]string{"HTTP_PROXY": "http://proxy:8080", "NO_PROXY": ".example.com"}, "http://example.com/", "http://proxy:8080"},
+		{map[string]string{"HTTP_PROXY": "http://proxy:8080", "NO_PROXY": ".example.com"}, "http://www.example.com/", ""},
+		{map[string]string{"HTTP_PROXY": "http://proxy:8080", "NO_PROXY": "10.0.0.0/8"}, "http://10.1.2.3/", ""},
+		{map[string]string{"HTTP_PROXY": "http://proxy:8080", "NO_PROXY": "10.0.0.0/8"}, "http://192.168.1.1/", "http://proxy:8080"},
+		{map[string]string{"HTTP_PROXY": "http://proxy:8080", "NO_PROXY": "*"}, "http://example.com/", ""},
+	}
+	for _, tt := range tests {
+		for _, k := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy", "REQUEST_METHOD"} {
+			t.Setenv(k, tt.env[k])
+		}
+		req, err := http.NewRequest("GET", tt.url, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := http.ProxyFromEnvironment(req)
+		if err != nil {
+			t.Errorf("%v %s: %v", tt.env, tt.url, err)
+			continue
+		}
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != tt.want {
+			t.Errorf("%v %s: proxy = %q; want %q", tt.env, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestClientExpectContinue(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Expect") != "100-continue" {
+			t.Errorf("Expect = %q; want 100-continue", r.Header.Get("Expect"))
+		}
+		if r.Header.Get("X-Reject") != "" {
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+	client := &http.Client{ExpectContinueTimeout: 5 * time.Second}
+	send := func(reject bool, body *strings.Reader) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest("POST", "http://"+addr+"/", nil, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reject {
+			req.Header.Set("X-Reject", "1")
+		}
+		resp, err := client.Send(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	t.Run("Continue", func(t *testing.T) {
+		resp := send(false, strings.NewReader("hello"))
+		defer resp.Body.Close()
+		if body, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || string(body) != "hello" {
+			t.Errorf("got %d %q; want 200 %q", resp.StatusCode, body, "hello")
+		}
+	})
+
+	t.Run("ExpectationFailed", func(t *testing.T) {
+		body := strings.NewReader("hello")
+		resp := send(true, body)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusExpectationFailed {
+			t.Errorf("StatusCode = %d; want %d", resp.StatusCode, http.StatusExpectationFailed)
+		}
+		if body.Len() != 5 {
+			t.Errorf("client sent %d bytes of the body after a final response; want 0", 5-body.Len())
+		}
+	})
+}
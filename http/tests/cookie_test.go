@@ -79,8 +79,13 @@ var writeSetCookiesTests = []struct {
 		"cookie-14=samesite-strict; SameSite=Strict",
 	},
 	{
-		&http.Cookie{Name: "cookie-15", Value: "samesite-none", SameSite: http.SameSiteNoneMode},
-		"cookie-15=samesite-none; SameSite=None",
+		&http.Cookie{Name: "cookie-15", Value: "samesite-none", SameSite: http.SameSiteNoneMode, Secure: true},
+		"cookie-15=samesite-none; Secure; SameSite=None",
+	},
+	// SameSite=None requires Secure; the attribute is dropped otherwise.
+	{
+		&http.Cookie{Name: "cookie-16", Value: "samesite-none-insecure", SameSite: http.SameSiteNoneMode},
+		"cookie-16=samesite-none-insecure",
 	},
 	// The "special" cookies have values containing commas or spaces which
 	// are disallowed by RFC 6265 but are common in the wild.
@@ -162,8 +167,10 @@ func TestWriteSetCookies(t *testing.T) {
 		}
 	}
 
-	if got, sub := logbuf.String(), "dropping domain attribute"; !strings.Contains(got, sub) {
-		t.Errorf("Expected substring %q in log output. Got:\n%s", sub, got)
+	for _, sub := range []string{"dropping domain attribute", "dropping SameSite attribute"} {
+		if got := logbuf.String(); !strings.Contains(got, sub) {
+			t.Errorf("Expected substring %q in log output. Got:\n%s", sub, got)
+		}
 	}
 }
 
@@ -196,6 +203,26 @@ func TestSetCookie(t *testing.T) {
 	}
 }
 
+func TestSetCookieInvalid(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	var logbuf strings.Builder
+	log.SetOutput(&logbuf)
+
+	m := make(http.Header)
+	http.SetCookie(headerOnlyResponseWriter(m), nil)
+	http.SetCookie(headerOnlyResponseWriter(m), &http.Cookie{Name: "", Value: "empty-name"})
+	http.SetCookie(headerOnlyResponseWriter(m), &http.Cookie{Name: "bad-value", Value: "foo\"bar"})
+	http.SetCookie(headerOnlyResponseWriter(m), &http.Cookie{Name: "bad-path", Value: "v", Path: "/foo;bar/"})
+	http.SetCookie(headerOnlyResponseWriter(m), &http.Cookie{Name: "__Host-insecure", Value: "v", Path: "/"})
+	http.SetCookie(headerOnlyResponseWriter(m), &http.Cookie{Name: "cookie-1", Value: "one", Path: "/restricted/"})
+	if got, want := m["Set-Cookie"], []string{"cookie-1=one; Path=/restricted/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Set-Cookie = %q; want %q", got, want)
+	}
+	if got, sub := logbuf.String(), "dropping Set-Cookie"; !strings.Contains(got, sub) {
+		t.Errorf("Expected substring %q in log output. Got:\n%s", sub, got)
+	}
+}
+
 var addCookieTests = []struct {
 	Cookies []*http.Cookie
 	Raw     string
@@ -264,6 +291,16 @@ var readSetCookiesTests = []struct {
 			Raw:        ".ASPXAUTH=7E3AA; expires=Wed, 07-Mar-2012 14:25:06 GMT; path=/; HttpOnly",
 		}},
 	},
+	{
+		http.Header{"Set-Cookie": {"utc=v; Expires=Wed, 21 Oct 2015 07:28:00 UTC"}},
+		[]*http.Cookie{{
+			Name:       "utc",
+			Value:      "v",
+			Expires:    time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC),
+			RawExpires: "Wed, 21 Oct 2015 07:28:00 UTC",
+			Raw:        "utc=v; Expires=Wed, 21 Oct 2015 07:28:00 UTC",
+		}},
+	},
 	{
 		http.Header{"Set-Cookie": {"ASP.NET_SessionId=foo; path=/; HttpOnly"}},
 		[]*http.Cookie{{
@@ -445,6 +482,28 @@ var readCookiesTests = []struct {
 	},
 }
 
+func TestCookiePartitionedPriority(t *testing.T) {
+	c := &http.Cookie{Name: "chip", Value: "v", Path: "/", Secure: true, Partitioned: true, Priority: "high"}
+	const raw = "chip=v; Path=/; Secure; Partitioned; Priority=High"
+	if got := c.String(); got != raw {
+		t.Fatalf("String() = %q; want %q", got, raw)
+	}
+
+	got := http.ReadSetCookies(http.Header{"Set-Cookie": {raw}})
+	want := []*http.Cookie{{Name: "chip", Value: "v", Path: "/", Secure: true, Partitioned: true, Priority: "High", Raw: raw}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readSetCookies: have\n%s\nwant\n%s\n", toJSON(got), toJSON(want))
+	}
+
+	got = http.ReadSetCookies(http.Header{"Set-Cookie": {"chip=v; priority=urgent"}})
+	if len(got) != 1 || got[0].Priority != "" || !reflect.DeepEqual(got[0].Unparsed, []string{"priority=urgent"}) {
+		t.Errorf("readSetCookies with unknown priority: have %s", toJSON(got))
+	}
+	if err := (&http.Cookie{Name: "chip", Priority: "urgent"}).Valid(); err == nil {
+		t.Errorf("Valid() with unknown priority returned nil; want error")
+	}
+}
+
 func TestReadCookies(t *testing.T) {
 	for i, tt := range readCookiesTests {
 		for n := 0; n < 2; n++ { // to verify readCookies doesn't mutate its input
@@ -547,10 +606,19 @@ func TestCookieValid(t *testing.T) {
 		{&http.Cookie{Name: "invalid-path", Path: "/foo;bar/"}, false},
 		{&http.Cookie{Name: "invalid-domain", Domain: "example.com:80"}, false},
 		{&http.Cookie{Name: "invalid-expiry", Value: "", Expires: time.Date(1600, 1, 1, 1, 1, 1, 1, time.UTC)}, false},
+		{&http.Cookie{Name: "invalid-samesite-none", Value: "foo", SameSite: http.SameSiteNoneMode}, false},
+		{&http.Cookie{Name: "valid-samesite-none", Value: "foo", SameSite: http.SameSiteNoneMode, Secure: true}, true},
 		{&http.Cookie{Name: "valid-empty"}, true},
 		{&http.Cookie{Name: "valid-expires", Value: "foo", Path: "/bar", Domain: "example.com", Expires: time.Unix(0, 0)}, true},
 		{&http.Cookie{Name: "valid-max-age", Value: "foo", Path: "/bar", Domain: "example.com", MaxAge: 60}, true},
 		{&http.Cookie{Name: "valid-all-fields", Value: "foo", Path: "/bar", Domain: "example.com", Expires: time.Unix(0, 0), MaxAge: 0}, true},
+		{&http.Cookie{Name: "__Secure-id", Value: "foo", Secure: true}, true},
+		{&http.Cookie{Name: "__Secure-id", Value: "foo"}, false},
+		{&http.Cookie{Name: "__Host-id", Value: "foo", Path: "/", Secure: true}, true},
+		{&http.Cookie{Name: "__host-id", Value: "foo", Path: "/"}, false},
+		{&http.Cookie{Name: "__Host-id", Value: "foo", Path: "/", Secure: true, Domain: "example.com"}, false},
+		{&http.Cookie{Name: "__Host-id", Value: "foo", Path: "/bar", Secure: true}, false},
+		{&http.Cookie{Name: "__Host-id", Value: "foo", Secure: true}, false},
 	}
 
 	for _, tt := range tests {
@@ -564,6 +632,23 @@ func TestCookieValid(t *testing.T) {
 	}
 }
 
+func TestReadSetCookiesPrefix(t *testing.T) {
+	h := http.Header{"Set-Cookie": {
+		"__Host-ok=1; Path=/; Secure",
+		"__Host-insecure=2; Path=/",
+		"__Host-domain=3; Path=/; Secure; Domain=example.com",
+		"__Secure-ok=4; Secure; Domain=example.com",
+		"__Secure-insecure=5",
+	}}
+	var names []string
+	for _, c := range http.ReadSetCookies(h) {
+		names = append(names, c.Name)
+	}
+	if want := []string{"__Host-ok", "__Secure-ok"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ReadSetCookies returned %q; want %q", names, want)
+	}
+}
+
 func BenchmarkCookieString(b *testing.B) {
 	const wantCookieString = `cookie-9=i3e01nf61b6t23bvfmplnanol3; Path=/restricted/; Domain=example.com; Expires=Tue, 10 Nov 2009 23:00:00 GMT; Max-Age=3600`
 	c := &http.Cookie{
@@ -0,0 +1,335 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	http "github.com/curol/network/http"
+)
+
+func TestServeContentConditional(t *testing.T) {
+	modtime := time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Etag", `"v1"`)
+		http.ServeContent(w, r, "hello.txt", modtime, strings.NewReader("hello"))
+	})
+
+	tests := []struct {
+		method string
+		header string
+		code   int
+	}{
+		{"GET", "", http.StatusOK},
+		{"GET", `If-None-Match: "v1"`, http.StatusNotModified},
+		{"GET", `If-None-Match: W/"v1"`, http.StatusNotModified},
+		{"GET", `If-None-Match: "v2"`, http.StatusOK},
+		{"POST", `If-None-Match: "v1"`, http.StatusPreconditionFailed},
+		{"GET", "If-Modified-Since: " + http.FormatTime(modtime), http.StatusNotModified},
+		{"GET", "If-Modified-Since: " + http.FormatTime(modtime.Add(-time.Hour)), http.StatusOK},
+		{"GET", `If-Match: "v1"`, http.StatusOK},
+		{"GET", `If-Match: "v2"`, http.StatusPreconditionFailed},
+		{"GET", "If-Unmodified-Since: " + http.FormatTime(modtime.Add(-time.Hour)), http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		raw := tt.method + " / HTTP/1.1\r\nHost: " + addr + "\r\nConnection: close\r\n"
+		if tt.header != "" {
+			raw += tt.header + "\r\n"
+		}
+		io.WriteString(conn, raw+"\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn))
+		if err != nil {
+			conn.Close()
+			t.Fatalf("%s %q: %v", tt.method, tt.header, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		conn.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s %q: StatusCode = %d; want %d", tt.method, tt.header, resp.StatusCode, tt.code)
+			continue
+		}
+		if tt.code == http.StatusOK {
+			if string(body) != "hello" {
+				t.Errorf("%s %q: body = %q; want %q", tt.method, tt.header, body, "hello")
+			}
+			if got := resp.Header.Get("Last-Modified"); got != http.FormatTime(modtime) {
+				t.Errorf("%s %q: Last-Modified = %q; want %q", tt.method, tt.header, got, http.FormatTime(modtime))
+			}
+		}
+	}
+}
+
+func TestRequestIsConditional(t *testing.T) {
+	for _, h := range []string{"", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		req, err := http.NewRequest("GET", "http://example.com/", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h != "" {
+			req.Header.Set(h, `"v1"`)
+		}
+		if got, want := req.IsConditional(), h != ""; got != want {
+			t.Errorf("IsConditional with %q = %v; want %v", h, got, want)
+		}
+	}
+}
+
+func TestServeContentETag(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "hello.txt", time.Time{}, strings.NewReader("hello"))
+	})
+	get := func(header string) *http.Response {
+		t.Helper()
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\nConnection: close\r\n"+header+"\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		return resp
+	}
+
+	etag := get("").Header.Get("Etag")
+	if etag == "" {
+		t.Fatal("no ETag set")
+	}
+	if again := get("").Header.Get("Etag"); again != etag {
+		t.Errorf("second ETag = %q; want %q", again, etag)
+	}
+	want, err := http.ETag(strings.NewReader("hello"))
+	if err != nil || etag != want {
+		t.Errorf("ETag = %q; want ETag(content) = %q, %v", etag, want, err)
+	}
+	if resp := get("If-None-Match: " + etag + "\r\n"); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("If-None-Match %s: StatusCode = %d; want %d", etag, resp.StatusCode, http.StatusNotModified)
+	}
+}
+
+// errReadSeeker seeks like a reader of size bytes but fails every read.
+type errReadSeeker struct{ size int64 }
+
+func (r errReadSeeker) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+func (r errReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekEnd {
+		return r.size + offset, nil
+	}
+	return offset, nil
+}
+
+func TestServeContentModTimeETag(t *testing.T) {
+	modtime := time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+	serveWith := func(modtime time.Time, content io.ReadSeeker, header, value string) *httptest.ResponseRecorder {
+		t.Helper()
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(w, r, "hello.txt", modtime, content)
+		})
+		req := httptest.NewRequest("HEAD", "/", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		http.ToStdHandler(handler).ServeHTTP(rec, req)
+		return rec
+	}
+	serve := func(modtime time.Time, content io.ReadSeeker) *httptest.ResponseRecorder {
+		t.Helper()
+		return serveWith(modtime, content, "", "")
+	}
+
+	// With a modtime a weak ETag is derived without reading the content.
+	rec := serve(modtime, errReadSeeker{5})
+	etag := rec.Header().Get("Etag")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("StatusCode = %d, ETag = %q; want %d and a weak ETag", rec.Code, etag, http.StatusOK)
+	}
+	// If-None-Match compares weakly, If-Match strongly.
+	preconds := []struct {
+		header, value string
+		code          int
+	}{
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", strings.TrimPrefix(etag, "W/"), http.StatusNotModified},
+		{"If-Match", etag, http.StatusPreconditionFailed},
+	}
+	for _, tt := range preconds {
+		if rec := serveWith(modtime, strings.NewReader("hello"), tt.header, tt.value); rec.Code != tt.code {
+			t.Errorf("%s: %s: StatusCode = %d; want %d", tt.header, tt.value, rec.Code, tt.code)
+		}
+	}
+	if again := serve(modtime, strings.NewReader("hello")).Header().Get("Etag"); again != etag {
+		t.Errorf("ETag of same size and modtime = %q; want %q", again, etag)
+	}
+	if other := serve(modtime.Add(time.Second), strings.NewReader("hello")).Header().Get("Etag"); other == etag {
+		t.Errorf("ETag after modtime changed = %q; want a different tag", other)
+	}
+	if other := serve(modtime, strings.NewReader("hello!")).Header().Get("Etag"); other == etag {
+		t.Errorf("ETag after size changed = %q; want a different tag", other)
+	}
+
+	// Without one the content is hashed, and a failed read is reported as such.
+	rec = serve(time.Time{}, errReadSeeker{5})
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("read error: StatusCode = %d; want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if got := rec.Body.String(); !strings.Contains(got, "error reading content") {
+		t.Errorf("read error: body = %q; want it to mention reading", got)
+	}
+
+	// Large content without a modtime isn't read to hash it.
+	rec = serve(time.Time{}, errReadSeeker{1 << 20})
+	if rec.Code != http.StatusOK || rec.Header().Get("Etag") != "" {
+		t.Errorf("large content: StatusCode = %d, ETag = %q; want %d and no ETag", rec.Code, rec.Header().Get("Etag"), http.StatusOK)
+	}
+	// Unless the caller opts in by setting the ETag itself.
+	large := strings.NewReader(strings.Repeat("x", 1<<20))
+	want, err := http.ETag(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag, err := http.ETag(large)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Etag", etag)
+		http.ServeContent(w, r, "large.txt", time.Time{}, large)
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", want)
+	rec = httptest.NewRecorder()
+	http.ToStdHandler(handler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("opted-in ETag: StatusCode = %d; want %d", rec.Code, http.StatusNotModified)
+	}
+}
+
+// gatedReader blocks reads past limit until gate is closed.
+type gatedReader struct {
+	*bytes.Reader
+	limit int64
+	gate  chan struct{}
+}
+
+func (r *gatedReader) Read(p []byte) (int, error) {
+	if pos, _ := r.Seek(0, io.SeekCurrent); pos >= r.limit {
+		<-r.gate
+	}
+	return r.Reader.Read(p)
+}
+
+func TestServeContentStreams(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<16) // 1 MB
+	gate := make(chan struct{})
+	modtime := time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		content := &gatedReader{Reader: bytes.NewReader(data), limit: 64 << 10, gate: gate}
+		http.ServeContent(w, r, "large.bin", modtime, content)
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\nConnection: close\r\n\r\n")
+
+	// The head and the first part of the body must arrive while the
+	// rest of the content is still unread.
+	resp, err := http.ReadResponse(bufio.NewReader(conn))
+	if err != nil {
+		close(gate)
+		t.Fatalf("response not sent before content was fully read: %v", err)
+	}
+	if resp.ContentLength != int64(len(data)) {
+		t.Errorf("ContentLength = %d; want %d", resp.ContentLength, len(data))
+	}
+	head := make([]byte, 32<<10)
+	if _, err := io.ReadFull(resp.Body, head); err != nil {
+		close(gate)
+		t.Fatalf("body not streamed before content was fully read: %v", err)
+	}
+	close(gate)
+	rest, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := append(head, rest...); !bytes.Equal(body, data) {
+		t.Errorf("body differs from content: got %d bytes; want %d", len(body), len(data))
+	}
+}
+
+func TestFileServerFS(t *testing.T) {
+	modtime := time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":   {Data: []byte("<html>home</html>"), ModTime: modtime},
+		"hello.txt":    {Data: []byte("hello"), ModTime: modtime},
+		"css/site.css": {Data: []byte("body {}"), ModTime: modtime},
+		"data":         {Data: []byte("\x00\x01binary"), ModTime: modtime},
+		"docs/a.txt":   {Data: []byte("a"), ModTime: modtime},
+	}
+	handler := http.ToStdHandler(http.FileServerFS(fsys))
+
+	tests := []struct {
+		path  string
+		code  int
+		ctype string
+		body  string
+	}{
+		{"/hello.txt", http.StatusOK, "text/plain; charset=utf-8", "hello"},
+		{"/css/site.css", http.StatusOK, "text/css; charset=utf-8", "body {}"},
+		{"/", http.StatusOK, "text/html; charset=utf-8", "<html>home</html>"},
+		{"/data", http.StatusOK, "application/octet-stream", "\x00\x01binary"},
+		{"/css/../hello.txt", http.StatusOK, "text/plain; charset=utf-8", "hello"},
+		{"/missing.txt", http.StatusNotFound, "", ""},
+		{"/docs/", http.StatusNotFound, "", ""}, // no index.html, and directories aren't listed
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.code {
+			t.Errorf("GET %s: StatusCode = %d; want %d", tt.path, rec.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		if got := rec.Header().Get("Content-Type"); got != tt.ctype {
+			t.Errorf("GET %s: Content-Type = %q; want %q", tt.path, got, tt.ctype)
+		}
+		if got := rec.Body.String(); got != tt.body {
+			t.Errorf("GET %s: body = %q; want %q", tt.path, got, tt.body)
+		}
+		if got := rec.Header().Get("Last-Modified"); got != http.FormatTime(modtime) {
+			t.Errorf("GET %s: Last-Modified = %q; want %q", tt.path, got, http.FormatTime(modtime))
+		}
+	}
+
+	// The file is served with ServeContent, so conditional requests work.
+	req := httptest.NewRequest("GET", "/hello.txt", nil)
+	req.Header.Set("If-Modified-Since", http.FormatTime(modtime))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional GET: StatusCode = %d; want %d", rec.Code, http.StatusNotModified)
+	}
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	http "github.com/curol/network/http"
+)
+
+func TestHeaderWriteOrdered(t *testing.T) {
+	h := http.Header{
+		"Date":         {"Tue, 10 Nov 2009 23:00:00 GMT"},
+		"Content-Type": {"application/json"},
+		"X-Request-Id": {"abc", "def"},
+		"User-Agent":   {"test"},
+	}
+	var buf bytes.Buffer
+	if err := http.HeaderWriteOrdered(&buf, h, []string{"x-request-id", "Date", "Missing", "content-type"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "X-Request-Id: abc\r\n" +
+		"X-Request-Id: def\r\n" +
+		"Date: Tue, 10 Nov 2009 23:00:00 GMT\r\n" +
+		"Content-Type: application/json\r\n"
+	if got := buf.String(); got != want {
+		t.Errorf("HeaderWriteOrdered =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestHeaderDelHas(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Type", "text/plain")
+	h.Set("X-Empty", "")
+	h["X-Nil"] = nil
+
+	for _, key := range []string{"content-type", "CONTENT-TYPE", "Content-Type", "x-empty", "X-NIL"} {
+		if !http.HeaderHas(h, key) {
+			t.Errorf("HeaderHas(%q) = false; want true", key)
+		}
+	}
+	if http.HeaderHas(h, "x-missing") {
+		t.Error(`HeaderHas("x-missing") = true; want false`)
+	}
+
+	h.Del("content-type")
+	if http.HeaderHas(h, "Content-Type") {
+		t.Error(`HeaderHas("Content-Type") after Del("content-type") = true; want false`)
+	}
+	if _, ok := h["Content-Type"]; ok {
+		t.Errorf("Content-Type still in map after Del: %v", h)
+	}
+	h.Del("X-NIL")
+	if len(h) != 1 || !http.HeaderHas(h, "X-Empty") {
+		t.Errorf("header after Del = %v; want only X-Empty", h)
+	}
+}
+
+func TestHeaderGetDefaultGetAll(t *testing.T) {
+	h := http.Header{}
+	h.Add("Accept", "text/html")
+	h.Add("accept", "application/json")
+	h.Set("X-Empty", "")
+
+	if got := http.HeaderGetDefault(h, "ACCEPT", "*/*"); got != "text/html" {
+		t.Errorf(`HeaderGetDefault("ACCEPT") = %q; want %q`, got, "text/html")
+	}
+	if got := http.HeaderGetDefault(h, "x-empty", "default"); got != "" {
+		t.Errorf(`HeaderGetDefault("x-empty") = %q; want ""`, got)
+	}
+	if got := http.HeaderGetDefault(h, "X-Missing", "default"); got != "default" {
+		t.Errorf(`HeaderGetDefault("X-Missing") = %q; want %q`, got, "default")
+	}
+
+	want := []string{"text/html", "application/json"}
+	all := http.HeaderGetAll(h, "accept")
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf(`HeaderGetAll("accept") = %q; want %q`, all, want)
+	}
+	all[0] = "modified"
+	if got := h.Get("Accept"); got != "text/html" {
+		t.Errorf("modifying the HeaderGetAll result changed the header to %q", got)
+	}
+	if got := http.HeaderGetAll(h, "X-Missing"); got != nil {
+		t.Errorf(`HeaderGetAll("X-Missing") = %q; want nil`, got)
+	}
+}
@@ -0,0 +1,297 @@
+package tests
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	http "github.com/curol/network/http"
+)
+
+func TestMuxMatchEscapedSegments(t *testing.T) {
+	mux := http.NewMux()
+	for _, pattern := range []string{"/a%2fb/", "/a/b/", "/%61bc", "/100%25"} {
+		pattern := pattern
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(pattern))
+		})
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	server := http.NewServer("tcp", ln.Addr().String())
+	server.Handler = mux
+	go server.Serve(ln)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/a%2Fb/", "/a%2fb/"},
+		{"/a%2fb/", "/a%2fb/"},
+		{"/a/b/", "/a/b/"},
+		{"/abc", "/%61bc"},
+		{"/%61%62c", "/%61bc"},
+		{"/100%25", "/100%25"},
+		{"/a%2Fb", "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		io.WriteString(conn, "GET "+tt.path+" HTTP/1.1\r\nHost: "+ln.Addr().String()+"\r\nConnection: close\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn))
+		if err != nil {
+			conn.Close()
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		conn.Close()
+		if string(body) != tt.want {
+			t.Errorf("%s: matched %q; want %q", tt.path, body, tt.want)
+		}
+	}
+}
+
+func TestMuxConflictingEscapedPatterns(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering /a after /%61 did not panic")
+		}
+	}()
+	mux := http.NewMux()
+	h := func(w http.ResponseWriter, r *http.Request) {}
+	mux.HandleFunc("/%61", h)
+	mux.HandleFunc("/a", h)
+}
+
+func TestRequestPattern(t *testing.T) {
+	mux := http.NewMux()
+	for _, pattern := range []string{"/items", "/%61bc"} {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Pattern())
+		})
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/items", "/items"},
+		{"/abc", "/%61bc"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		http.ToStdHandler(mux).ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("%s: Pattern() = %q; want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.MethodNotAllowed(w, []string{"PUT", "GET", "DELETE", "GET", "HEAD"})
+	})
+	http.ToStdHandler(h).ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Code = %d; want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if got, want := rec.Header().Get("Allow"), "DELETE, GET, HEAD, PUT"; got != want {
+		t.Errorf("Allow = %q; want %q", got, want)
+	}
+
+	// A Router replies 405 to a method not routed for a known path.
+	router := http.NewRouter()
+	router.GET("/items", func(w http.ResponseWriter, r *http.Request) {})
+	router.POST("/items", func(w http.ResponseWriter, r *http.Request) {})
+	routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { router.Route(r, w) })
+	rec = httptest.NewRecorder()
+	http.ToStdHandler(routed).ServeHTTP(rec, httptest.NewRequest("DELETE", "/items", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Router: Code = %d; want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if got, want := rec.Header().Get("Allow"), "GET, POST"; got != want {
+		t.Errorf("Router: Allow = %q; want %q", got, want)
+	}
+}
+
+func TestMuxHostPattern(t *testing.T) {
+	mux := http.NewMux()
+	for _, pattern := range []string{"/v6", "[fe80::1]/v6", "[fe80::2%eth0]/v6", "example.com/v6"} {
+		pattern := pattern
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, pattern)
+		})
+	}
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"[fe80::1]", "[fe80::1]/v6"},
+		{"[fe80::1]:8080", "[fe80::1]/v6"},
+		{"[fe80::1%25en0]:8080", "[fe80::1]/v6"},
+		{"[fe80::1%en0]", "[fe80::1]/v6"},
+		{"[fe80::2]:80", "[fe80::2%eth0]/v6"},
+		{"example.com:8080", "example.com/v6"},
+		{"[fe80::3]:8080", "/v6"},
+		{"other.example", "/v6"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/v6", nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		http.ToStdHandler(mux).ServeHTTP(rec, req)
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("Host %s: matched %q; want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestStripPrefixEscapedPath(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	server := http.NewServer("tcp", ln.Addr().String())
+	mux := http.NewMux()
+	mux.HandleFunc("/files/a%2Fb", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "path="+r.URL.Path+" raw="+r.URL.RawPath)
+	})
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.Header.Get("X-Prefix")
+		http.StripPrefix(prefix, mux).ServeHTTP(w, r)
+	})
+	go server.Serve(ln)
+
+	tests := []struct {
+		target string
+		prefix string
+		code   int
+		body   string
+	}{
+		// The escaped slash is kept in RawPath, so the stripped path still
+		// matches "/files/a%2Fb" rather than "/files/a/b".
+		{"/api/files/a%2Fb", "/api", http.StatusOK, "path=/files/a/b raw=/files/a%2Fb"},
+		// The prefix must match the escaped path too: "/api/files/a" stops
+		// in the middle of the escaped segment "a%2Fb".
+		{"/api/files/a%2Fb", "/api/files/a", http.StatusNotFound, ""},
+		{"/other/files/a%2Fb", "/api", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		io.WriteString(conn, "GET "+tt.target+" HTTP/1.1\r\nHost: example.com\r\nX-Prefix: "+tt.prefix+"\r\nConnection: close\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn))
+		if err != nil {
+			conn.Close()
+			t.Fatalf("%s: %v", tt.target, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		conn.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s without %s: StatusCode = %d; want %d", tt.target, tt.prefix, resp.StatusCode, tt.code)
+		}
+		if tt.code == http.StatusOK && string(body) != tt.body {
+			t.Errorf("%s without %s: body = %q; want %q", tt.target, tt.prefix, body, tt.body)
+		}
+	}
+}
+
+// TestMuxConcurrentRegister is meant to be run with -race.
+func TestMuxConcurrentRegister(t *testing.T) {
+	mux := http.NewMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "root")
+	})
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			pattern := "/h" + strconv.Itoa(i)
+			if i%2 == 1 {
+				pattern = "example.com" + pattern // also sets the mux's hosts flag
+			}
+			mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, pattern)
+			})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			req := httptest.NewRequest("GET", "/h"+strconv.Itoa(i), nil)
+			http.ToStdHandler(mux).ServeHTTP(httptest.NewRecorder(), req)
+		}
+	}()
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		want := "/h" + strconv.Itoa(i)
+		if i%2 == 1 {
+			want = "example.com" + want
+		}
+		req := httptest.NewRequest("GET", "/h"+strconv.Itoa(i), nil)
+		rec := httptest.NewRecorder()
+		http.ToStdHandler(mux).ServeHTTP(rec, req)
+		if got := rec.Body.String(); got != want {
+			t.Errorf("GET /h%d matched %q; want %q", i, got, want)
+		}
+	}
+}
+
+func TestMuxMethodPatterns(t *testing.T) {
+	mux := http.NewMux()
+	for _, pattern := range []string{"GET /items", "POST /items", "DELETE /items/1", "/any", "PUT api.example.com/items"} {
+		pattern := pattern
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Pattern())
+		})
+	}
+	tests := []struct {
+		method string
+		target string
+		code   int
+		body   string // pattern matched, if code is 200
+		allow  string
+	}{
+		{"GET", "/items", http.StatusOK, "GET /items", ""},
+		{"POST", "/items", http.StatusOK, "POST /items", ""},
+		{"HEAD", "/items", http.StatusOK, "", ""}, // GET patterns match HEAD
+		{"DELETE", "/items", http.StatusMethodNotAllowed, "", "GET, HEAD, POST"},
+		{"GET", "/items/1", http.StatusMethodNotAllowed, "", "DELETE"},
+		{"PATCH", "/any", http.StatusOK, "/any", ""},
+		{"GET", "/missing", http.StatusNotFound, "", ""},
+		{"PUT", "http://api.example.com/items", http.StatusOK, "PUT api.example.com/items", ""},
+		{"DELETE", "http://api.example.com/items", http.StatusMethodNotAllowed, "", "GET, HEAD, POST, PUT"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		http.ToStdHandler(mux).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s %s: Code = %d; want %d", tt.method, tt.target, rec.Code, tt.code)
+			continue
+		}
+		if tt.code == http.StatusOK && tt.method != "HEAD" && rec.Body.String() != tt.body {
+			t.Errorf("%s %s: matched %q; want %q", tt.method, tt.target, rec.Body.String(), tt.body)
+		}
+		if got := rec.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s: Allow = %q; want %q", tt.method, tt.target, got, tt.allow)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	http "github.com/curol/network/http"
+)
+
+func TestParseAccept(t *testing.T) {
+	got := http.ParseAccept("text/html, Application/JSON;q=0.9, text/*;level=1;q=0.5, image/png;q=x, */*;q=0")
+	want := []http.AcceptSpec{
+		{Value: "text/html", Q: 1},
+		{Value: "application/json", Q: 0.9},
+		{Value: "text/*", Q: 0.5},
+		{Value: "*/*", Q: 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAccept = %v; want %v", got, want)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	jsonXML := []string{"application/json", "application/xml"}
+	tests := []struct {
+		accept []string // nil for no Accept header
+		offers []string
+		want   string
+	}{
+		{nil, jsonXML, "application/json"},
+		{nil, nil, ""},
+		{[]string{"application/xml"}, jsonXML, "application/xml"},
+		{[]string{"application/json;q=0.5, application/xml"}, jsonXML, "application/xml"},
+		{[]string{"application/*"}, jsonXML, "application/json"}, // tie goes to the first offer
+		{[]string{"*/*"}, jsonXML, "application/json"},
+		{[]string{"text/html"}, jsonXML, ""},
+		{[]string{"application/json;q=0"}, jsonXML, ""},
+		// The most specific range decides: application/json is excluded
+		// even though application/* accepts it.
+		{[]string{"application/*, application/json;q=0"}, jsonXML, "application/xml"},
+		{[]string{"text/*;q=0.3, */*;q=0.1"}, []string{"image/png", "text/plain"}, "text/plain"},
+		// Multiple Accept fields are combined.
+		{[]string{"text/plain;q=0.2", "application/xml;q=0.8"}, []string{"text/plain", "application/xml"}, "application/xml"},
+		{[]string{"APPLICATION/XML"}, []string{"Application/Xml"}, "Application/Xml"},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", "http://example.com/", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range tt.accept {
+			req.Header.Add("Accept", v)
+		}
+		if got := http.Negotiate(req, tt.offers); got != tt.want {
+			t.Errorf("Negotiate(Accept %q, %q) = %q; want %q", tt.accept, tt.offers, got, tt.want)
+		}
+	}
+}
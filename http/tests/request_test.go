@@ -3,11 +3,19 @@ package tests
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
+	"net"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	libhttp "net/http"
 
@@ -132,6 +140,57 @@ var newRequestHostTests = []struct {
 	{"http://[fe80::1%25en0]:/", "[fe80::1%en0]"},
 }
 
+func TestDumpRequestRestoresBody(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://example.com/", nil, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Dump(); !strings.HasSuffix(got, "\r\n\r\nhello") {
+		t.Errorf("Dump() = %q; want body %q", got, "hello")
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello" {
+		t.Errorf("Body after Dump = %q; want %q", body, "hello")
+	}
+}
+
+func TestDumpRequestContentLength(t *testing.T) {
+	cConn, sConn := net.Pipe()
+	defer cConn.Close()
+	defer sConn.Close()
+	go io.WriteString(cConn, "hello")
+
+	// A body read straight from a live connection that is never closed.
+	req, err := http.NewRequest("POST", "http://example.com/upload", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Body = io.NopCloser(sConn)
+	req.ContentLength = 5
+
+	done := make(chan string, 1)
+	go func() { done <- req.Dump() }()
+	select {
+	case got := <-done:
+		if !strings.HasSuffix(got, "\r\n\r\nhello") {
+			t.Errorf("Dump() = %q; want body %q", got, "hello")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Dump blocked reading past Content-Length")
+	}
+
+	// The connection is still open.
+	go io.WriteString(cConn, "more")
+	sConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(sConn, buf); err != nil || string(buf) != "more" {
+		t.Errorf("read after Dump = %q, %v; want %q, nil", buf, err, "more")
+	}
+}
+
 func TestNewRequestHost(t *testing.T) {
 	for i, tt := range newRequestHostTests {
 		req, err := http.NewRequest("GET", tt.in, nil, nil)
@@ -145,6 +204,101 @@ func TestNewRequestHost(t *testing.T) {
 	}
 }
 
+func TestNewRequestProto(t *testing.T) {
+	req, err := http.NewRequestProto("GET", "http://example.com/path", "HTTP/1.0", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Proto != "HTTP/1.0" || req.ProtoMajor != 1 || req.ProtoMinor != 0 {
+		t.Errorf("Proto = %q (%d.%d); want HTTP/1.0 (1.0)", req.Proto, req.ProtoMajor, req.ProtoMinor)
+	}
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "GET /path HTTP/1.0\r\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("request = %q; want request line %q", got, want)
+	}
+
+	for _, proto := range []string{"HTTP/2.0", "HTTP/1", "SPDY/3"} {
+		if _, err := http.NewRequestProto("GET", "http://example.com/", proto, nil, nil); !errors.Is(err, http.ErrUnsupportedProtocol) {
+			t.Errorf("NewRequestProto(%q) error = %v; want %v", proto, err, http.ErrUnsupportedProtocol)
+		}
+	}
+}
+
+func TestRequestQueryParams(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com/search?q=go&page=1", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetQueryParam("page", "2")
+	req.SetQueryParam("sort", "new & hot")
+	if got, want := req.URL.RequestURI(), "/search?page=2&q=go&sort=new+%26+hot"; got != want {
+		t.Errorf("RequestURI after SetQueryParam = %q; want %q", got, want)
+	}
+	req.DelQueryParam("q")
+	req.DelQueryParam("missing")
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "GET /search?page=2&sort=new+%26+hot HTTP/1.1\r\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("request = %q; want request line %q", got, want)
+	}
+	req.DelQueryParam("page")
+	req.DelQueryParam("sort")
+	if got, want := req.URL.RequestURI(), "/search"; got != want {
+		t.Errorf("RequestURI without params = %q; want %q", got, want)
+	}
+}
+
+func TestRequestBindJSON(t *testing.T) {
+	type payload struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	newReq := func(ctype, body string) *http.Request {
+		req, err := http.NewRequest("POST", "http://example.com/items", nil, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", ctype)
+		return req
+	}
+
+	var got payload
+	if err := newReq("application/json; charset=utf-8", `{"name":"gopher","count":3}`).BindJSON(&got); err != nil {
+		t.Fatalf("BindJSON: %v", err)
+	}
+	if want := (payload{Name: "gopher", Count: 3}); got != want {
+		t.Errorf("BindJSON = %+v; want %+v", got, want)
+	}
+
+	req := newReq("text/plain", `{"name":"gopher"}`)
+	if err := req.BindJSON(&got); err != http.ErrNotJSON {
+		t.Errorf("BindJSON with text/plain = %v; want %v", err, http.ErrNotJSON)
+	}
+	if b, _ := io.ReadAll(req.Body); string(b) != `{"name":"gopher"}` {
+		t.Errorf("body after rejected BindJSON = %q; want it unread", b)
+	}
+
+	var syntaxErr *json.SyntaxError
+	for _, body := range []string{`{"name":`, ""} {
+		err := newReq("application/json", body).BindJSON(&got)
+		if !errors.As(err, &syntaxErr) || !strings.HasPrefix(err.Error(), "http: malformed JSON body") {
+			t.Errorf("BindJSON(%q) = %v; want a malformed JSON body error", body, err)
+		}
+	}
+
+	req = newReq("application/json", `{"name":"a long name"}`)
+	req.Body = http.MaxBytesReader(nil, req.Body, 8)
+	var mbe *http.MaxBytesError
+	if err := req.BindJSON(&got); !errors.As(err, &mbe) || mbe.Limit != 8 {
+		t.Errorf("BindJSON over MaxBytesReader limit = %v; want *MaxBytesError with limit 8", err)
+	}
+}
+
 var parseHTTPVersionTests = []struct {
 	vers         string
 	major, minor int
@@ -213,6 +367,108 @@ func TestRequestWriteBufferedWriter(t *testing.T) {
 	}
 }
 
+func TestRequestWriteFlushes(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://foo.com/", nil, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "POST / HTTP/1.1\r\n" +
+		"Host: foo.com\r\n" +
+		"User-Agent: " + defaultUserAgent + "\r\n" +
+		"Content-Length: 5\r\n" +
+		"\r\n" +
+		"hello"
+	if got := buf.String(); got != want {
+		t.Errorf("Write =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestRequestWriteNoBody(t *testing.T) {
+	tests := []struct {
+		method string
+		cl     int64
+		want   string
+	}{
+		{"POST", 0, "Content-Length: 0\r\n"},
+		{"POST", -1, "Content-Length: 0\r\n"},
+		{"PUT", 0, "Content-Length: 0\r\n"},
+		{"GET", 0, ""},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, "http://foo.com/", nil, http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.ContentLength = tt.cl
+		var buf bytes.Buffer
+		if err := req.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		got := buf.String()
+		if strings.Contains(got, "Transfer-Encoding") {
+			t.Errorf("%s with NoBody and ContentLength %d is chunked:\n%s", tt.method, tt.cl, got)
+		}
+		if tt.want != "" && !strings.Contains(got, tt.want) {
+			t.Errorf("%s with NoBody and ContentLength %d missing %q:\n%s", tt.method, tt.cl, tt.want, got)
+		}
+		if tt.want == "" && strings.Contains(got, "Content-Length") {
+			t.Errorf("%s with NoBody has Content-Length:\n%s", tt.method, got)
+		}
+		if !strings.HasSuffix(got, "\r\n\r\n") {
+			t.Errorf("%s with NoBody wrote a body:\n%q", tt.method, got)
+		}
+	}
+}
+
+func TestRequestWriteChunked(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://foo.com/", nil, struct{ io.Reader }{strings.NewReader("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	if !strings.Contains(got, "Transfer-Encoding: chunked\r\n") {
+		t.Errorf("missing Transfer-Encoding: chunked:\n%s", got)
+	}
+	if strings.Contains(got, "Content-Length") {
+		t.Errorf("chunked request has Content-Length:\n%s", got)
+	}
+	if want := "\r\n\r\n5\r\nhello\r\n0\r\n\r\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("body = %q; want suffix %q", got, want)
+	}
+
+	// HTTP/1.0 has no chunked encoding, so the body is buffered.
+	req, err = http.NewRequest("POST", "http://foo.com/", nil, struct{ io.Reader }{strings.NewReader("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/1.0", 1, 0
+	buf.Reset()
+	if err := req.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got = buf.String()
+	if strings.Contains(got, "Transfer-Encoding") || !strings.Contains(got, "Content-Length: 5\r\n") {
+		t.Errorf("HTTP/1.0 request framing:\n%s", got)
+	}
+	if !strings.HasSuffix(got, "\r\n\r\nhello") {
+		t.Errorf("HTTP/1.0 body = %q; want suffix %q", got, "\r\n\r\nhello")
+	}
+}
+
+func TestNewRequestBadURL(t *testing.T) {
+	if _, err := http.NewRequest("GET", "http://[::1", nil, nil); err == nil {
+		t.Error("NewRequest with malformed URL: got nil error")
+	}
+}
+
 func TestRequestBadHostHeader(t *testing.T) {
 	got := []string{}
 	req, err := http.NewRequest("GET", "http://foo/after", nil, nil)
@@ -316,6 +572,580 @@ func TestNewRequestGetBody(t *testing.T) {
 	}
 }
 
+func TestNewJSONRequest(t *testing.T) {
+	req, err := http.NewJSONRequest("POST", "http://foo.tld/items", map[string]string{"name": "gopher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const body = `{"name":"gopher"}`
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q; want %q", got, "application/json")
+	}
+	if req.ContentLength != int64(len(body)) {
+		t.Errorf("ContentLength = %d; want %d", req.ContentLength, len(body))
+	}
+	if req.GetBody == nil {
+		t.Fatal("GetBody = nil")
+	}
+
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	if err := req.Write(bw); err != nil {
+		t.Fatal(err)
+	}
+	bw.Flush()
+	got := buf.String()
+	for _, want := range []string{
+		"POST /items HTTP/1.1\r\n",
+		"Content-Type: application/json\r\n",
+		fmt.Sprintf("Content-Length: %d\r\n", len(body)),
+		"\r\n\r\n" + body,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("request missing %q; got:\n%s", want, got)
+		}
+	}
+
+	rc, err := req.GetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slurp, _ := io.ReadAll(rc); string(slurp) != body {
+		t.Errorf("GetBody = %q; want %q", slurp, body)
+	}
+}
+
+func TestNewFormRequest(t *testing.T) {
+	form := url.Values{
+		"name": {"gopher"},
+		"tags": {"a b", "c&d"},
+	}
+	req, err := http.NewFormRequest("POST", "http://foo.tld/submit", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.Header.Get("Content-Type"), "application/x-www-form-urlencoded"; got != want {
+		t.Errorf("Content-Type = %q; want %q", got, want)
+	}
+	if req.GetBody == nil {
+		t.Fatal("GetBody = nil")
+	}
+
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	if err := req.Write(bw); err != nil {
+		t.Fatal(err)
+	}
+	bw.Flush()
+
+	sreq, err := http.ReadRequest(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sreq.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sreq.PostForm, form) {
+		t.Errorf("PostForm = %v; want %v", sreq.PostForm, form)
+	}
+}
+
+func TestNewMultipartRequest(t *testing.T) {
+	fields := map[string]string{"name": "gopher", "lang": "go"}
+	files := map[string]io.Reader{"avatar.png": strings.NewReader("PNG data")}
+	req, err := http.NewMultipartRequest("POST", "http://foo.tld/upload", fields, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Content-Type"); !strings.HasPrefix(got, "multipart/form-data; boundary=") {
+		t.Errorf("Content-Type = %q; want multipart/form-data with boundary", got)
+	}
+	if req.GetBody == nil {
+		t.Fatal("GetBody = nil")
+	}
+
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	if err := req.Write(bw); err != nil {
+		t.Fatal(err)
+	}
+	bw.Flush()
+
+	sreq, err := http.ReadRequest(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sreq.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range fields {
+		if got := sreq.FormValue(k); got != v {
+			t.Errorf("FormValue(%q) = %q; want %q", k, got, v)
+		}
+	}
+	f, fh, err := sreq.FormFile("avatar.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if fh.Filename != "avatar.png" {
+		t.Errorf("Filename = %q; want %q", fh.Filename, "avatar.png")
+	}
+	if slurp, _ := io.ReadAll(f); string(slurp) != "PNG data" {
+		t.Errorf("file contents = %q; want %q", slurp, "PNG data")
+	}
+}
+
+func TestRequestSign(t *testing.T) {
+	key := []byte("secret")
+	headers := []string{"Host", "Content-Type", "Date"}
+	newReq := func() *http.Request {
+		req, err := http.NewRequest("POST", "http://foo.tld/hook", map[string][]string{
+			"Content-Type": {"application/json"},
+			"Date":         {"Tue, 10 Nov 2009 23:00:00 GMT"},
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	req := newReq()
+	sig, err := req.Sign(key, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig == "" || req.Header.Get("Signature") != sig {
+		t.Fatalf("Signature header = %q; want %q", req.Header.Get("Signature"), sig)
+	}
+	if !req.VerifySignature(key, headers) {
+		t.Error("VerifySignature = false for signed request; want true")
+	}
+	if req.VerifySignature([]byte("other"), headers) {
+		t.Error("VerifySignature = true with wrong key; want false")
+	}
+
+	tampered := newReq()
+	tampered.Header.Set("Signature", sig)
+	tampered.Header.Set("Content-Type", "text/plain")
+	if tampered.VerifySignature(key, headers) {
+		t.Error("VerifySignature = true for tampered header; want false")
+	}
+
+	tampered = newReq()
+	tampered.Header.Set("Signature", sig)
+	tampered.Method = "DELETE"
+	if tampered.VerifySignature(key, headers) {
+		t.Error("VerifySignature = true for tampered request line; want false")
+	}
+
+	// The signature survives the wire, though the server's Host isn't in its Header.
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	received, err := http.ReadRequest(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !received.VerifySignature(key, headers) {
+		t.Errorf("VerifySignature = false for signed request read from the wire:\n%s", received.Dump())
+	}
+	received.Host = "evil.tld"
+	if received.VerifySignature(key, headers) {
+		t.Error("VerifySignature = true for tampered Host; want false")
+	}
+
+	missing := newReq()
+	missing.Header.Del("Date")
+	if _, err := missing.Sign(key, headers); !errors.Is(err, http.ErrMissingSignedHeader) {
+		t.Errorf("Sign without Date error = %v; want %v", err, http.ErrMissingSignedHeader)
+	}
+	if http.HeaderHas(missing.Header, "Signature") {
+		t.Error("Sign without Date set the Signature header")
+	}
+	missing.Header.Set("Signature", sig)
+	if missing.VerifySignature(key, headers) {
+		t.Error("VerifySignature = true without Date; want false")
+	}
+}
+
+func TestReadRequestChunkedTrailer(t *testing.T) {
+	raw := "POST /upload HTTP/1.1\r\n" +
+		"Host: foo.tld\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"Trailer: Checksum\r\n" +
+		"\r\n" +
+		"5\r\nhello\r\n" +
+		"6\r\n world\r\n" +
+		"0\r\n" +
+		"Checksum: abc123\r\n" +
+		"Unannounced: nope\r\n" +
+		"\r\n" +
+		"GET /next HTTP/1.1\r\nHost: foo.tld\r\n\r\n"
+	br := bufio.NewReader(strings.NewReader(raw))
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength != -1 || !reflect.DeepEqual(req.TransferEncoding, []string{"chunked"}) {
+		t.Errorf("ContentLength = %d, TransferEncoding = %q; want -1, [chunked]", req.ContentLength, req.TransferEncoding)
+	}
+	if got := req.Trailer.Get("Checksum"); got != "" {
+		t.Errorf("Trailer before body read = %q; want empty", got)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello world" {
+		t.Errorf("Body = %q; want %q", body, "hello world")
+	}
+	want := http.Header{"Checksum": {"abc123"}}
+	if !reflect.DeepEqual(req.Trailer, want) {
+		t.Errorf("Trailer = %v; want %v", req.Trailer, want)
+	}
+
+	next, err := http.ReadRequest(br)
+	if err != nil {
+		t.Fatalf("reading request after chunked body: %v", err)
+	}
+	if next.URL.Path != "/next" {
+		t.Errorf("next request path = %q; want %q", next.URL.Path, "/next")
+	}
+}
+
+func TestReadRequestTrailerValidation(t *testing.T) {
+	// A trailer field that wasn't announced is dropped.
+	raw := "POST / HTTP/1.1\r\n" +
+		"Host: foo.tld\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"Trailer: Checksum\r\n" +
+		"\r\n" +
+		"5\r\nhello\r\n0\r\n" +
+		"Checksum: abc123\r\n" +
+		"X-Unannounced: smuggled\r\n" +
+		"\r\n"
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(req.Body); err != nil {
+		t.Fatal(err)
+	}
+	if want := (http.Header{"Checksum": {"abc123"}}); !reflect.DeepEqual(req.Trailer, want) {
+		t.Errorf("Trailer = %v; want %v", req.Trailer, want)
+	}
+
+	// Announcing a field that frames or routes the message is rejected.
+	for _, key := range []string{"Content-Length", "transfer-encoding", "Trailer", "Host"} {
+		raw := "POST / HTTP/1.1\r\nHost: foo.tld\r\nTransfer-Encoding: chunked\r\nTrailer: Checksum, " + key + "\r\n\r\n0\r\n\r\n"
+		if _, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw))); !errors.Is(err, http.ErrInvalidHeaderLine) {
+			t.Errorf("Trailer announcing %s: err = %v; want %v", key, err, http.ErrInvalidHeaderLine)
+		}
+	}
+}
+
+func TestReadRequestChunkedAndContentLength(t *testing.T) {
+	// A proxy framing the body by Content-Length would forward "5\r\nhel" and
+	// take the rest for the next request.
+	raw := "POST / HTTP/1.1\r\n" +
+		"Host: foo.tld\r\n" +
+		"Content-Length: 6\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"\r\n" +
+		"5\r\nhello\r\n0\r\n\r\n" +
+		"GET /next HTTP/1.1\r\nHost: foo.tld\r\n\r\n"
+	br := bufio.NewReader(strings.NewReader(raw))
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength != -1 {
+		t.Errorf("ContentLength = %d; want -1", req.ContentLength)
+	}
+	if http.HeaderHas(req.Header, "Content-Length") {
+		t.Errorf("Content-Length header = %q; want it removed", req.Header.Get("Content-Length"))
+	}
+	if body, err := io.ReadAll(req.Body); err != nil || string(body) != "hello" {
+		t.Errorf("Body = %q, %v; want %q", body, err, "hello")
+	}
+	next, err := http.ReadRequest(br)
+	if err != nil {
+		t.Fatalf("reading request after chunked body: %v", err)
+	}
+	if next.URL.Path != "/next" {
+		t.Errorf("next request path = %q; want %q", next.URL.Path, "/next")
+	}
+}
+
+func TestReadRequestTransferEncodingLines(t *testing.T) {
+	// Transfer-Encoding and its codings may be split over several lines.
+	raw := "POST / HTTP/1.1\r\n" +
+		"Host: foo.tld\r\n" +
+		"Transfer-Encoding: \r\n" +
+		"Transfer-Encoding: Chunked\r\n" +
+		"Content-Length: 3\r\n" +
+		"\r\n" +
+		"5\r\nhello\r\n0\r\n\r\n"
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength != -1 || http.HeaderHas(req.Header, "Content-Length") {
+		t.Errorf("ContentLength = %d, Content-Length header %q; want -1 and none", req.ContentLength, req.Header.Get("Content-Length"))
+	}
+	if body, err := io.ReadAll(req.Body); err != nil || string(body) != "hello" {
+		t.Errorf("Body = %q, %v; want %q", body, err, "hello")
+	}
+
+	// A coding that isn't chunked on any line is never framed by Content-Length.
+	for _, te := range []string{
+		"Transfer-Encoding: gzip\r\nTransfer-Encoding: chunked\r\n",
+		"Transfer-Encoding: chunked\r\nTransfer-Encoding: gzip\r\n",
+		"Transfer-Encoding: xchunked\r\n",
+	} {
+		raw := "POST / HTTP/1.1\r\nHost: foo.tld\r\n" + te + "Content-Length: 3\r\n\r\n5\r\n"
+		if req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw))); err == nil {
+			t.Errorf("%q: got request with ContentLength %d; want error", te, req.ContentLength)
+		}
+	}
+}
+
+func TestReadRequestDuplicateHost(t *testing.T) {
+	raw := "GET / HTTP/1.1\r\nHost: a.tld\r\nHost: b.tld\r\n\r\n"
+	if req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw))); err == nil {
+		t.Errorf("got request with Host %q; want error", req.Host)
+	}
+}
+
+func TestRequestBytesRead(t *testing.T) {
+	head := "POST /upload HTTP/1.1\r\n" +
+		"Host: foo.tld\r\n" +
+		"Content-Length: 11\r\n" +
+		"\r\n"
+	raw := head + "hello world"
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.BytesRead(); got != int64(len(head)) {
+		t.Errorf("BytesRead before reading the body = %d; want %d", got, len(head))
+	}
+	io.ReadAll(req.Body)
+	if got := req.BytesRead(); got != int64(len(raw)) {
+		t.Errorf("BytesRead = %d; want %d", got, len(raw))
+	}
+	if got := req.WithContext(context.Background()).BytesRead(); got != int64(len(raw)) {
+		t.Errorf("BytesRead of a copy = %d; want %d", got, len(raw))
+	}
+
+	// The count is shared with clones, whichever copy reads the body.
+	req, err = http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := req.CloneWithContext(context.Background())
+	io.ReadAll(clone.Body)
+	if got := clone.BytesRead(); got != int64(len(raw)) {
+		t.Errorf("BytesRead of a clone = %d; want %d", got, len(raw))
+	}
+	if got := req.BytesRead(); got != int64(len(raw)) {
+		t.Errorf("BytesRead after reading through a clone = %d; want %d", got, len(raw))
+	}
+
+	client, err := http.NewRequest("GET", "http://foo.tld/", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := client.BytesRead(); got != 0 {
+		t.Errorf("BytesRead of a client request = %d; want 0", got)
+	}
+}
+
+func TestReadRequestSniffContentType(t *testing.T) {
+	body := `{"name": "gopher", "tags": ["a", "b"]}`
+	raw := "POST /items HTTP/1.1\r\nHost: foo.tld\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "text/plain; charset=utf-8"; req.ContentType != want {
+		t.Errorf("ContentType = %q; want %q", req.ContentType, want)
+	}
+	if http.HeaderHas(req.Header, "Content-Type") {
+		t.Errorf("Content-Type header = %q; want none", req.Header.Get("Content-Type"))
+	}
+	if got, err := io.ReadAll(req.Body); err != nil || string(got) != body {
+		t.Errorf("Body = %q, %v; want %q", got, err, body)
+	}
+
+	// A body that isn't received yet, like one of a request expecting "100 Continue", isn't sniffed.
+	raw = "POST /items HTTP/1.1\r\nHost: foo.tld\r\nExpect: 100-continue\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n"
+	req, err = http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentType != "" {
+		t.Errorf("ContentType with Expect: 100-continue = %q; want empty", req.ContentType)
+	}
+}
+
+func TestReadRequestPipelinedBody(t *testing.T) {
+	raw := "POST /first HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\n\r\nfirst" +
+		"POST /second HTTP/1.1\r\nHost: example.com\r\nContent-Length: 6\r\n\r\nsecond"
+	br := bufio.NewReader(strings.NewReader(raw))
+	for _, want := range []struct{ path, body string }{{"/first", "first"}, {"/second", "second"}} {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.URL.Path != want.path {
+			t.Errorf("Path = %q; want %q", req.URL.Path, want.path)
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil || string(body) != want.body {
+			t.Errorf("%s: body = %q, %v; want %q", want.path, body, err, want.body)
+		}
+	}
+	if _, err := http.ReadRequest(br); err != io.EOF {
+		t.Errorf("ReadRequest after the last request: %v; want EOF", err)
+	}
+
+	// A body cut short by the end of the connection is an error.
+	truncated := "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 10\r\n\r\nshort"
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(truncated)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := io.ReadAll(req.Body); err != io.ErrUnexpectedEOF || string(body) != "short" {
+		t.Errorf("truncated body = %q, %v; want %q, %v", body, err, "short", io.ErrUnexpectedEOF)
+	}
+}
+
+func TestReadRequestTargetForms(t *testing.T) {
+	tests := []struct {
+		target  string
+		scheme  string
+		urlHost string
+		host    string
+	}{
+		// origin-form: the host comes from the Host header.
+		{"/index.html?q=1", "", "", "www.google.com"},
+		// absolute-form: the host in the URL wins over the Host header.
+		{"http://example.com/index.html?q=1", "http", "example.com", "example.com"},
+		{"https://example.com:8443/index.html?q=1", "https", "example.com:8443", "example.com:8443"},
+	}
+	for _, tt := range tests {
+		raw := "GET " + tt.target + " HTTP/1.1\r\nHost: www.google.com\r\n\r\n"
+		req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.target, err)
+			continue
+		}
+		if req.URL.Scheme != tt.scheme || req.URL.Host != tt.urlHost || req.URL.Path != "/index.html" || req.URL.RawQuery != "q=1" {
+			t.Errorf("%s: URL = %#v; want scheme %q, host %q, path %q, query %q", tt.target, req.URL, tt.scheme, tt.urlHost, "/index.html", "q=1")
+		}
+		if req.Host != tt.host {
+			t.Errorf("%s: Host = %q; want %q", tt.target, req.Host, tt.host)
+		}
+		if req.RequestURI != tt.target {
+			t.Errorf("%s: RequestURI = %q; want %q", tt.target, req.RequestURI, tt.target)
+		}
+	}
+}
+
+func TestReadRequestLineWhitespace(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+	}{
+		{"GET / HTTP/1.1\r\n", true},
+		{"GET / HTTP/1.1\n", true},
+		{"GET  / HTTP/1.1\r\n", false},      // double space
+		{"GET /  HTTP/1.1\r\n", false},      // double space
+		{" GET / HTTP/1.1\r\n", false},      // leading space
+		{"GET\t/\tHTTP/1.1\r\n", false},     // tab separated
+		{"GET / HTTP/1.1 extra\r\n", false}, // trailing garbage
+		{"GET / HTTP/1.1 \r\n", false},      // trailing space
+		{"GET /\x00 HTTP/1.1\r\n", false},   // control character
+		{"GET / HTTP/1.1\x7f\r\n", false},   // DEL
+		{"GET / HTTP/1.1\r\r\n", false},     // stray carriage return
+	}
+	for _, tt := range tests {
+		raw := tt.line + "Host: www.google.com\r\n\r\n"
+		_, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("ReadRequest(%q): err = %v; want ok %v", tt.line, err, tt.ok)
+		}
+	}
+}
+
+func TestReadRequestObsFold(t *testing.T) {
+	for _, fold := range []string{" ", "\t"} {
+		raw := "GET / HTTP/1.1\r\nHost: www.google.com\r\nX-Folded: first\r\n" + fold + "second\r\n\r\n"
+		_, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+		if err == nil || !strings.Contains(err.Error(), "obsolete line folding") {
+			t.Errorf("fold %q: err = %v; want obsolete line folding error", fold, err)
+		}
+	}
+}
+
+func TestReadRequestErrors(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want error
+	}{
+		{"GET /\r\nHost: www.google.com\r\n\r\n", http.ErrInvalidRequestLine},
+		{"GET  / HTTP/1.1\r\nHost: www.google.com\r\n\r\n", http.ErrInvalidRequestLine},
+		{"GET foo HTTP/1.1\r\nHost: www.google.com\r\n\r\n", http.ErrInvalidRequestLine},
+		{"GET / HTTP/1.1\r\nHost www.google.com\r\n\r\n", http.ErrInvalidHeaderLine},
+		{"GET / HTTP/1.1\r\nHost: www.google.com\r\n folded\r\n\r\n", http.ErrInvalidHeaderLine},
+		{"GET / HTTP/2.0\r\nHost: www.google.com\r\n\r\n", http.ErrUnsupportedProtocol},
+		{"GET / FOO/1.1\r\nHost: www.google.com\r\n\r\n", http.ErrUnsupportedProtocol},
+		{"POST / HTTP/1.1\r\nHost: www.google.com\r\nContent-Length: 3\r\nContent-Length: 4\r\n\r\nabcd", http.ErrInvalidContentLength},
+		{"POST / HTTP/1.1\r\nHost: www.google.com\r\nContent-Length: 3, 4\r\n\r\nabcd", http.ErrInvalidContentLength},
+		{"POST / HTTP/1.1\r\nHost: www.google.com\r\nContent-Length: -1\r\n\r\n", http.ErrInvalidContentLength},
+		{"POST / HTTP/1.1\r\nHost: www.google.com\r\nContent-Length: 1x\r\n\r\n", http.ErrInvalidContentLength},
+		{"PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n", http.ErrHTTP2Preface},
+		{"PRI * HTTP/2.0\r\n\r\nXX\r\n\r\n", http.ErrUnsupportedProtocol}, // not quite the preface
+	}
+	for _, tt := range tests {
+		_, err := http.ReadRequest(bufio.NewReader(strings.NewReader(tt.raw)))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("ReadRequest(%q): err = %v; want %v", tt.raw, err, tt.want)
+		}
+	}
+
+	// The whole HTTP/2 preface is consumed, leaving the frames after it.
+	br := bufio.NewReader(strings.NewReader("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n\x00\x00\x00\x04"))
+	if _, err := http.ReadRequest(br); err != http.ErrHTTP2Preface {
+		t.Fatalf("ReadRequest(HTTP/2 preface): err = %v; want %v", err, http.ErrHTTP2Preface)
+	}
+	if rest, _ := io.ReadAll(br); string(rest) != "\x00\x00\x00\x04" {
+		t.Errorf("data after the preface = %q; want the first frame", rest)
+	}
+}
+
+func TestReadRequestDuplicateContentLength(t *testing.T) {
+	for _, raw := range []string{
+		"POST / HTTP/1.1\r\nHost: www.google.com\r\nContent-Length: 5\r\nContent-Length: 5\r\n\r\nhello",
+		"POST / HTTP/1.1\r\nHost: www.google.com\r\nContent-Length: 5, 5\r\n\r\nhello",
+	} {
+		req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+		if err != nil {
+			t.Errorf("ReadRequest(%q): %v", raw, err)
+			continue
+		}
+		if req.ContentLength != 5 {
+			t.Errorf("ReadRequest(%q): ContentLength = %d; want 5", raw, req.ContentLength)
+		}
+		if body, _ := io.ReadAll(req.Body); string(body) != "hello" {
+			t.Errorf("ReadRequest(%q): body = %q; want %q", raw, body, "hello")
+		}
+	}
+}
+
 // Issue 53181: verify Request.Cookie return the correct Cookie.
 // Return ErrNoCookie instead of the first cookie when name is "".
 func TestRequestCookie(t *testing.T) {
@@ -358,6 +1188,30 @@ func TestRequestCookie(t *testing.T) {
 	}
 }
 
+func TestRequestCookiesNamed(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com/", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Cookie", "foo=one; bar=two")
+	req.Header.Add("Cookie", "foo=three")
+
+	got := req.CookiesNamed("foo")
+	want := []*http.Cookie{
+		{Name: "foo", Value: "one"},
+		{Name: "foo", Value: "three"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CookiesNamed(%q) = %s; want %s", "foo", toJSON(got), toJSON(want))
+	}
+	if got := req.CookiesNamed("baz"); len(got) != 0 {
+		t.Errorf("CookiesNamed(%q) = %s; want empty", "baz", toJSON(got))
+	}
+	if got := req.CookiesNamed(""); len(got) != 0 {
+		t.Errorf("CookiesNamed(%q) = %s; want empty", "", toJSON(got))
+	}
+}
+
 func TestParseFormQuery(t *testing.T) {
 	req, _ := http.NewRequest(
 		"POST",
@@ -479,6 +1333,109 @@ func TestMultipartReader(t *testing.T) {
 	}
 }
 
+func TestRequestEachPart(t *testing.T) {
+	body := "--xxx\r\n" +
+		"Content-Disposition: form-data; name=\"field1\"\r\n\r\n" +
+		"value1\r\n" +
+		"--xxx\r\n" +
+		"Content-Disposition: form-data; name=\"file\"; filename=\"data.bin\"\r\n" +
+		"Content-Type: application/octet-stream\r\n\r\n" +
+		"binary data\r\n" +
+		"--xxx\r\n" +
+		"Content-Disposition: form-data; name=\"field2\"\r\n\r\n" +
+		"value2\r\n" +
+		"--xxx--\r\n"
+	newRequest := func() *http.Request {
+		return &http.Request{
+			Method: "POST",
+			Header: http.Header{"Content-Type": {`multipart/form-data; boundary=xxx`}},
+			Body:   io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	var got []string
+	err := newRequest().EachPart(func(p *multipart.Part) error {
+		b, err := io.ReadAll(p)
+		got = append(got, p.FormName()+"="+string(b))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"field1=value1", "file=binary data", "field2=value2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parts = %q; want %q", got, want)
+	}
+
+	// An error from fn stops the iteration.
+	errStop := errors.New("stop")
+	var names []string
+	err = newRequest().EachPart(func(p *multipart.Part) error {
+		names = append(names, p.FormName())
+		if p.FileName() != "" {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("EachPart error = %v; want %v", err, errStop)
+	}
+	if want := []string{"field1", "file"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("parts before the error = %q; want %q", names, want)
+	}
+}
+
+func TestMultipartBoundary(t *testing.T) {
+	// Generated boundaries are valid, unique, and round-trip through MultipartReader.
+	var boundaries []string
+	for i := 0; i < 2; i++ {
+		req, err := http.NewMultipartRequest("POST", "http://foo.tld/upload", map[string]string{"field": "value"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		boundaries = append(boundaries, params["boundary"])
+		mr, err := req.MultipartReader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := io.ReadAll(p); p.FormName() != "field" || string(b) != "value" {
+			t.Errorf("part %s = %q; want field = %q", p.FormName(), b, "value")
+		}
+	}
+	if len(boundaries[0]) != 60 || boundaries[0] == boundaries[1] {
+		t.Errorf("boundaries = %q; want two different 60-character boundaries", boundaries)
+	}
+
+	tests := []struct {
+		contentType string
+		want        error
+	}{
+		{`multipart/form-data; boundary="foo<bar>"`, http.ErrInvalidBoundary},
+		{`multipart/form-data; boundary="foo "`, http.ErrInvalidBoundary},
+		{`multipart/form-data; boundary=""`, http.ErrInvalidBoundary},
+		{`multipart/form-data; boundary=` + strings.Repeat("a", 71), http.ErrInvalidBoundary},
+		{`multipart/form-data`, http.ErrMissingBoundary},
+		{`multipart/form-data; boundary="'()+_,-./:=? ok"`, nil},
+	}
+	for _, tt := range tests {
+		req := &http.Request{
+			Method: "POST",
+			Header: http.Header{"Content-Type": {tt.contentType}},
+			Body:   io.NopCloser(new(bytes.Buffer)),
+		}
+		if _, err := req.MultipartReader(); err != tt.want {
+			t.Errorf("%s: MultipartReader error = %v; want %v", tt.contentType, err, tt.want)
+		}
+	}
+}
+
 // Issue 9305: ParseMultipartForm should populate PostForm too
 func TestParseMultipartFormPopulatesPostForm(t *testing.T) {
 	postData :=
@@ -608,3 +1565,191 @@ Content-Type: text/plain
 // }
 
 // compareReqToHttpRequest(want, got, t)
+
+func TestRequestDiff(t *testing.T) {
+	newReq := func() *http.Request {
+		req, err := http.NewRequest("POST", "http://example.com/path", map[string][]string{"X-Token": {"abc"}}, strings.NewReader("body"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	if diffs := newReq().Diff(newReq()); len(diffs) != 0 {
+		t.Errorf("identical requests: Diff = %q; want none", diffs)
+	}
+
+	r1, r2 := newReq(), newReq()
+	r2.Method = "PUT"
+	r2.Header.Set("X-Token", "xyz")
+	r2.Body = io.NopCloser(strings.NewReader("other"))
+	want := []string{
+		`Method: "POST" != "PUT"`,
+		`Header[X-Token]: ["abc"] != ["xyz"]`,
+		`Body: "body" != "other"`,
+	}
+	if diffs := r1.Diff(r2); !reflect.DeepEqual(diffs, want) {
+		t.Errorf("Diff = %q; want %q", diffs, want)
+	}
+	// The bodies can still be read after they were compared.
+	if b, _ := io.ReadAll(r1.Body); string(b) != "body" {
+		t.Errorf("body after Diff = %q; want %q", b, "body")
+	}
+}
+
+func TestRequestWriteConnect(t *testing.T) {
+	req, err := http.NewRequest("CONNECT", "example.com:443", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	line, _, _ := strings.Cut(buf.String(), "\r\n")
+	if want := "CONNECT example.com:443 HTTP/1.1"; line != want {
+		t.Errorf("request line = %q; want %q", line, want)
+	}
+
+	// A CONNECT request read by a proxy is written with the same target.
+	req, err = http.ReadRequest(bufio.NewReader(strings.NewReader("CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := req.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	line, _, _ = strings.Cut(buf.String(), "\r\n")
+	if want := "CONNECT example.com:443 HTTP/1.1"; line != want {
+		t.Errorf("request line of read request = %q; want %q", line, want)
+	}
+}
+
+func TestRequestWriteIPv6Zone(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://[fe80::1%25en0]:8080/path", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The zone is only meaningful to the client, so it isn't sent, while the port is.
+	tests := []struct {
+		name  string
+		write func(io.Writer) error
+		line  string
+	}{
+		{"Write", req.Write, "GET /path HTTP/1.1\r\n"},
+		{"WriteProxy", req.WriteProxy, "GET http://[fe80::1]:8080/path HTTP/1.1\r\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := tt.write(&buf); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		out := buf.String()
+		if !strings.HasPrefix(out, tt.line) || !strings.Contains(out, "\r\nHost: [fe80::1]:8080\r\n") {
+			t.Errorf("%s: request = %q; want request line %q and Host [fe80::1]:8080", tt.name, out, tt.line)
+		}
+	}
+	if req.Host != "[fe80::1%en0]:8080" {
+		t.Errorf("Host = %q after writing; want it unchanged", req.Host)
+	}
+}
+
+func TestRequestWriteProxyHopHeaders(t *testing.T) {
+	header := map[string][]string{
+		"Connection":          {"keep-alive, X-Hop"},
+		"Keep-Alive":          {"timeout=5"},
+		"Proxy-Connection":    {"keep-alive"},
+		"Upgrade":             {"websocket"},
+		"Te":                  {"trailers"},
+		"X-Hop":               {"1"},
+		"X-End-To-End":        {"1"},
+		"Proxy-Authorization": {"Basic dXNlcjpwYXNz"},
+	}
+	req, err := http.NewRequest("GET", "http://example.com/path", header, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := req.WriteProxy(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, h := range []string{"Connection:", "Keep-Alive:", "Proxy-Connection:", "Upgrade:", "Te:", "X-Hop:"} {
+		if strings.Contains(out, h) {
+			t.Errorf("forwarded request has hop-by-hop header %s\n%s", h, out)
+		}
+	}
+	for _, h := range []string{"GET http://example.com/path HTTP/1.1\r\n", "X-End-To-End: 1\r\n", "Proxy-Authorization: Basic dXNlcjpwYXNz\r\n"} {
+		if !strings.Contains(out, h) {
+			t.Errorf("forwarded request is missing %q\n%s", h, out)
+		}
+	}
+	if got := req.Header.Get("Connection"); got != "keep-alive, X-Hop" {
+		t.Errorf("WriteProxy changed the request's Connection header to %q", got)
+	}
+}
+
+func TestRequestCloneWithContext(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com/", map[string][]string{"X-Orig": {"1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "example.com:8080"
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	clone := req.CloneWithContext(ctx)
+	if clone.Context() != ctx {
+		t.Errorf("clone.Context() = %v; want the provided context", clone.Context())
+	}
+	if req.Context().Value(ctxKey{}) != nil {
+		t.Error("original request has the clone's context value")
+	}
+
+	clone.Header.Set("X-Orig", "2")
+	clone.Header.Set("X-Clone", "1")
+	if got := req.Header.Get("X-Orig"); got != "1" {
+		t.Errorf("original X-Orig = %q after changing the clone's; want %q", got, "1")
+	}
+	if http.HeaderHas(req.Header, "X-Clone") {
+		t.Error("header added to the clone was added to the original")
+	}
+
+	if clone.Host != req.Host {
+		t.Errorf("clone.Host = %q; want %q", clone.Host, req.Host)
+	}
+	clone.URL.Path = "/clone"
+	if req.URL.Path != "/" {
+		t.Errorf("original URL.Path = %q after changing the clone's; want %q", req.URL.Path, "/")
+	}
+}
+
+func TestRequestWithValue(t *testing.T) {
+	type userKey struct{}
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, http.WithValue(r, userKey{}, r.Header.Get("X-User")))
+		})
+	}
+	addr := newTestServer(t, auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := http.Value(r, userKey{}).(string)
+		if http.Value(r, "missing") != nil {
+			user += " (unexpected value for missing key)"
+		}
+		io.WriteString(w, user)
+	})).ServeHTTP)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\nX-User: gopher\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "gopher" {
+		t.Errorf("user seen by handler = %q; want %q", body, "gopher")
+	}
+}
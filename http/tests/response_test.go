@@ -0,0 +1,599 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	http "github.com/curol/network/http"
+)
+
+func TestResponseSetStatus(t *testing.T) {
+	res := http.NewResponse(nil)
+	res.SetStatus(404)
+	if res.StatusCode != 404 {
+		t.Errorf("StatusCode = %d; want 404", res.StatusCode)
+	}
+	if res.StatusText != "Not Found" {
+		t.Errorf("StatusText = %q; want %q", res.StatusText, "Not Found")
+	}
+	if res.Status != "404 Not Found" {
+		t.Errorf("Status = %q; want %q", res.Status, "404 Not Found")
+	}
+	if got, want := res.StatusLine(), "HTTP/1.1 404 Not Found"; got != want {
+		t.Errorf("StatusLine() = %q; want %q", got, want)
+	}
+}
+
+func TestResponseStatusHelpers(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(*http.Response)
+		code int
+		text string
+	}{
+		{"Ok", (*http.Response).Ok, 200, "OK"},
+		{"MovedPermanently", (*http.Response).MovedPermanently, 301, "Moved Permanently"},
+		{"Found", (*http.Response).Found, 302, "Found"},
+		{"NotModified", (*http.Response).NotModified, 304, "Not Modified"},
+		{"BadRequest", (*http.Response).BadRequest, 400, "Bad Request"},
+		{"Unauthorized", (*http.Response).Unauthorized, 401, "Unauthorized"},
+		{"Forbidden", (*http.Response).Forbidden, 403, "Forbidden"},
+		{"NotFound", (*http.Response).NotFound, 404, "Not Found"},
+		{"MethodNotAllowed", (*http.Response).MethodNotAllowed, 405, "Method Not Allowed"},
+		{"TooManyRequests", (*http.Response).TooManyRequests, 429, "Too Many Requests"},
+		{"InternalServerError", (*http.Response).InternalServerError, 500, "Internal Server Error"},
+		{"ServiceUnavailable", (*http.Response).ServiceUnavailable, 503, "Service Unavailable"},
+	}
+	for _, tt := range tests {
+		res := http.NewResponse(nil)
+		tt.set(res)
+		if res.StatusCode != tt.code || res.StatusText != tt.text {
+			t.Errorf("%s: got %d %q; want %d %q", tt.name, res.StatusCode, res.StatusText, tt.code, tt.text)
+		}
+	}
+}
+
+func TestResponseRedirect(t *testing.T) {
+	res := http.NewResponse(nil)
+	if err := res.Redirect("/login", http.StatusFound); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := res.Header.Get("Location"), "/login"; got != want {
+		t.Errorf("Location = %q; want %q", got, want)
+	}
+	if res.StatusCode != http.StatusFound || res.StatusText != "Found" {
+		t.Errorf("status = %d %q; want %d %q", res.StatusCode, res.StatusText, http.StatusFound, "Found")
+	}
+
+	res = http.NewResponse(nil)
+	res.SetStatus(http.StatusAccepted)
+	if err := res.Redirect("/login", http.StatusOK); err == nil {
+		t.Error("Redirect with non-3xx code returned no error")
+	}
+	if loc := res.Header.Get("Location"); loc != "" || res.StatusCode != http.StatusAccepted {
+		t.Errorf("after failed Redirect: Location = %q, StatusCode = %d; want the response unchanged", loc, res.StatusCode)
+	}
+}
+
+func TestResponseDecodeJSON(t *testing.T) {
+	type payload struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	want := payload{Name: "gopher", Count: 3}
+
+	res := http.NewResponse(nil)
+	if err := res.JSON(want); err != nil {
+		t.Fatal(err)
+	}
+	var got payload
+	if err := res.DecodeJSON(&got); err != nil {
+		t.Fatalf("DecodeJSON: %v", err)
+	}
+	if got != want {
+		t.Errorf("DecodeJSON = %+v; want %+v", got, want)
+	}
+
+	res = http.NewResponse(nil)
+	res.Text(`{"name":"gopher","count":3}`)
+	if err := res.DecodeJSON(&got); err != http.ErrNotJSON {
+		t.Errorf("DecodeJSON with text/plain = %v; want %v", err, http.ErrNotJSON)
+	}
+
+	res = http.NewResponse(nil)
+	res.Text(`{"name":"gopher","count":3}`)
+	res.LenientJSON = true
+	got = payload{}
+	if err := res.DecodeJSON(&got); err != nil {
+		t.Fatalf("lenient DecodeJSON: %v", err)
+	}
+	if got != want {
+		t.Errorf("lenient DecodeJSON = %+v; want %+v", got, want)
+	}
+}
+
+func TestResponseJSONIndent(t *testing.T) {
+	res := http.NewResponse(nil)
+	if err := res.JSONIndent(map[string]any{"name": "gopher", "tags": []string{"a", "b"}}, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"name\": \"gopher\",\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}"
+	if body, _ := io.ReadAll(res.Body); string(body) != want {
+		t.Errorf("body = %q; want %q", body, want)
+	}
+	if got := res.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q; want application/json", got)
+	}
+	if got := res.Header.Get("Content-Length"); got != strconv.Itoa(len(want)) {
+		t.Errorf("Content-Length = %q; want %d", got, len(want))
+	}
+}
+
+func TestResponseJSONStream(t *testing.T) {
+	want := make([]int, 100000) // larger than any of the buffers on the way
+	for i := range want {
+		want[i] = i
+	}
+	res := http.NewResponse(nil)
+	res.Header.Set("Content-Length", "1") // stale, and removed
+	res.JSONStream(want)
+	var buf bytes.Buffer
+	if _, err := res.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	head, _, _ := strings.Cut(buf.String(), "\r\n\r\n")
+	if !strings.Contains(head, "Transfer-Encoding: chunked") || strings.Contains(head, "Content-Length") {
+		t.Errorf("head = %q; want chunked without Content-Length", head)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	if err := resp.DecodeJSON(&got); err != nil {
+		t.Fatalf("DecodeJSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %d ints; want the %d streamed", len(got), len(want))
+	}
+
+	// An encoding error fails reading the body.
+	res = http.NewResponse(nil)
+	res.JSONStream(make(chan int))
+	if _, err := io.ReadAll(res.Body); err == nil {
+		t.Error("reading the body of an unencodable value succeeded; want an error")
+	}
+}
+
+func TestResponseContentSetters(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(*http.Response)
+		body string
+	}{
+		{"Text", func(r *http.Response) { r.Text("hello") }, "hello"},
+		{"HTML", func(r *http.Response) { r.HTML("<p>hi</p>") }, "<p>hi</p>"},
+		{"XML", func(r *http.Response) { r.XML("<a/>") }, "<a/>"},
+		{"JSONP", func(r *http.Response) { r.JSONP("cb(1)") }, "cb(1)"},
+		{"JSON", func(r *http.Response) { r.JSON([]int{1, 2}) }, "[1,2]"},
+		{"JSONIndent", func(r *http.Response) { r.JSONIndent([]int{1}, "", " ") }, "[\n 1\n]"},
+	}
+	for _, tt := range tests {
+		res := http.NewResponse(nil)
+		tt.set(res)
+		if res.ContentLength != int64(len(tt.body)) {
+			t.Errorf("%s: ContentLength = %d; want %d", tt.name, res.ContentLength, len(tt.body))
+		}
+		var buf bytes.Buffer
+		if _, err := res.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		raw := buf.String()
+		if !strings.Contains(raw, "\r\nContent-Length: "+strconv.Itoa(len(tt.body))+"\r\n") || !strings.HasSuffix(raw, "\r\n\r\n"+tt.body) {
+			t.Errorf("%s: response = %q; want Content-Length: %d and body %q", tt.name, raw, len(tt.body), tt.body)
+		}
+	}
+}
+
+func TestResponseWriteKnownBodyLength(t *testing.T) {
+	tests := []struct {
+		name string
+		body io.ReadCloser
+		want string
+	}{
+		{"bytes.Buffer", io.NopCloser(bytes.NewBufferString("hello world")), "hello world"},
+		{"bytes.Reader", io.NopCloser(bytes.NewReader([]byte("hello"))), "hello"},
+		{"strings.Reader", io.NopCloser(strings.NewReader("hi")), "hi"},
+		{"empty bytes.Buffer", io.NopCloser(new(bytes.Buffer)), ""},
+	}
+	for _, tt := range tests {
+		res := http.NewResponse(nil)
+		res.Body = tt.body // ContentLength and the Content-Length header are unset
+		var buf bytes.Buffer
+		if _, err := res.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		raw := buf.String()
+		if !strings.Contains(raw, "\r\nContent-Length: "+strconv.Itoa(len(tt.want))+"\r\n") || !strings.HasSuffix(raw, "\r\n\r\n"+tt.want) {
+			t.Errorf("%s: response = %q; want Content-Length: %d and body %q", tt.name, raw, len(tt.want), tt.want)
+		}
+	}
+}
+
+func TestReadResponseLargeContentLength(t *testing.T) {
+	// 3000000000 overflows a 32-bit int.
+	raw := "HTTP/1.1 200 OK\r\nContent-Length: 3000000000\r\n\r\nhello"
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ContentLength != 3000000000 {
+		t.Errorf("ContentLength = %d; want 3000000000", resp.ContentLength)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "hello" {
+		t.Errorf("body = %q; want %q", body, "hello")
+	}
+
+	raw = "HTTP/1.1 200 OK\r\nContent-Length: -1\r\n\r\n"
+	if _, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw))); err == nil {
+		t.Error("ReadResponse with negative Content-Length succeeded; want error")
+	}
+}
+
+func BenchmarkResponseWriteFile(b *testing.B) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<16) // 1 MB
+	name := filepath.Join(b.TempDir(), "large.bin")
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		b.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(io.Discard, c)
+				c.Close()
+			}()
+		}
+	}()
+
+	bench := func(b *testing.B, wrap func(*os.File) io.ReadCloser) {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer conn.Close()
+		bw := bufio.NewWriter(conn)
+		b.SetBytes(int64(len(data)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			f, err := os.Open(name)
+			if err != nil {
+				b.Fatal(err)
+			}
+			res := http.NewResponse(nil)
+			res.Body = wrap(f)
+			res.ContentLength = int64(len(data))
+			if _, err := res.WriteTo(bw); err != nil {
+				b.Fatal(err)
+			}
+			if err := bw.Flush(); err != nil {
+				b.Fatal(err)
+			}
+			f.Close()
+		}
+	}
+	b.Run("File", func(b *testing.B) {
+		bench(b, func(f *os.File) io.ReadCloser { return f })
+	})
+	b.Run("Reader", func(b *testing.B) {
+		bench(b, func(f *os.File) io.ReadCloser { return struct{ io.ReadCloser }{f} })
+	})
+}
+
+func TestResponseWriteUnknownLength(t *testing.T) {
+	for _, proto := range []string{"HTTP/1.0", "HTTP/1.1"} {
+		req, err := http.ReadRequest(bufio.NewReader(strings.NewReader("GET / " + proto + "\r\nHost: example.com\r\n\r\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := http.NewResponse(nil)
+		res.Request = req
+		res.Body = io.NopCloser(strings.NewReader("hello"))
+		res.ContentLength = -1
+		var buf bytes.Buffer
+		bw := bufio.NewWriter(&buf)
+		if _, err := res.WriteTo(bw); err != nil {
+			t.Fatal(err)
+		}
+		bw.Flush()
+
+		raw := buf.String()
+		chunked := strings.Contains(raw, "Transfer-Encoding: chunked\r\n")
+		if proto == "HTTP/1.0" {
+			// HTTP/1.0 has no chunked encoding, so the body ends when the connection is closed.
+			if chunked || !strings.Contains(raw, "Connection: close\r\n") || !strings.HasSuffix(raw, "\r\n\r\nhello") {
+				t.Errorf("%s: response = %q; want Connection: close and an unchunked body", proto, raw)
+			}
+			continue
+		}
+		if !chunked {
+			t.Errorf("%s: response = %q; want Transfer-Encoding: chunked", proto, raw)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != "hello" {
+			t.Errorf("%s: body = %q; want %q", proto, body, "hello")
+		}
+	}
+}
+
+func TestReadResponseBufferedReader(t *testing.T) {
+	raw := "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nfirst" +
+		"HTTP/1.1 404 Not Found\r\nContent-Length: 6\r\n\r\nsecond" +
+		"trailing data"
+	br := bufio.NewReader(strings.NewReader(raw))
+	br.Peek(len(raw)) // buffer the whole input before reading the responses
+	for _, want := range []struct {
+		code int
+		body string
+	}{{200, "first"}, {404, "second"}} {
+		resp, err := http.ReadResponse(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want.code {
+			t.Errorf("StatusCode = %d; want %d", resp.StatusCode, want.code)
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != want.body {
+			t.Errorf("body = %q; want %q", body, want.body)
+		}
+	}
+	if rest, _ := io.ReadAll(br); string(rest) != "trailing data" {
+		t.Errorf("data after the responses = %q; want %q", rest, "trailing data")
+	}
+}
+
+func TestReadResponseChunkedOverridesContentLength(t *testing.T) {
+	raw := "HTTP/1.1 200 OK\r\nContent-Length: 3\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"5\r\nhello\r\n0\r\n\r\n" +
+		"HTTP/1.1 204 No Content\r\n\r\n"
+	br := bufio.NewReader(strings.NewReader(raw))
+	resp, err := http.ReadResponse(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ContentLength != -1 || resp.Header.Get("Content-Length") != "" {
+		t.Errorf("ContentLength = %d, header %q; want -1 and no Content-Length header", resp.ContentLength, resp.Header.Get("Content-Length"))
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "hello" {
+		t.Errorf("body = %q; want %q", body, "hello")
+	}
+	// The next response starts after the terminating chunk.
+	resp, err = http.ReadResponse(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("next StatusCode = %d; want %d", resp.StatusCode, http.StatusNoContent)
+	}
+}
+
+func TestReadResponseEmptyReason(t *testing.T) {
+	for _, line := range []string{"HTTP/1.1 200\r\n", "HTTP/1.1 200 \r\n"} {
+		resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(line + "Content-Length: 2\r\n\r\nok")))
+		if err != nil {
+			t.Errorf("ReadResponse(%q): %v", line, err)
+			continue
+		}
+		if resp.StatusCode != 200 || resp.StatusText != "" {
+			t.Errorf("ReadResponse(%q): status = %d %q; want 200 \"\"", line, resp.StatusCode, resp.StatusText)
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != "ok" {
+			t.Errorf("ReadResponse(%q): body = %q; want %q", line, body, "ok")
+		}
+	}
+	if _, err := http.ReadResponse(bufio.NewReader(strings.NewReader("HTTP/1.1\r\n\r\n"))); err == nil {
+		t.Error("ReadResponse accepted a status line without a status code")
+	}
+}
+
+func TestResponseBytesCounted(t *testing.T) {
+	head := "HTTP/1.1 200 OK\r\n" +
+		"Content-Length: 11\r\n" +
+		"\r\n"
+	raw := head + "hello world"
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.BytesRead(); got != int64(len(head)) {
+		t.Errorf("BytesRead before reading the body = %d; want %d", got, len(head))
+	}
+	io.ReadAll(resp.Body)
+	if got := resp.BytesRead(); got != int64(len(raw)) {
+		t.Errorf("BytesRead = %d; want %d", got, len(raw))
+	}
+
+	// WriteTo reports the bytes written, including chunk framing.
+	for _, length := range []int64{11, -1} {
+		res := http.NewResponse(nil)
+		res.Body = io.NopCloser(strings.NewReader("hello world"))
+		res.ContentLength = length
+		var buf bytes.Buffer
+		bw := bufio.NewWriter(&buf)
+		n, err := res.WriteTo(bw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bw.Flush()
+		if n != int64(buf.Len()) {
+			t.Errorf("ContentLength %d: WriteTo = %d; want %d", length, n, buf.Len())
+		}
+	}
+}
+
+func TestResponseWriteToWriter(t *testing.T) {
+	newResponse := func() *http.Response {
+		res := http.NewResponse(nil)
+		res.Header.Set("Content-Type", "text/plain")
+		res.Header.Set("Content-Length", "5")
+		res.Body = io.NopCloser(strings.NewReader("hello"))
+		res.ContentLength = 5
+		return res
+	}
+	check := func(name string, r io.Reader) {
+		t.Helper()
+		resp, err := http.ReadResponse(bufio.NewReader(r))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+			t.Errorf("%s: Content-Type = %q; want %q", name, got, "text/plain")
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != "hello" {
+			t.Errorf("%s: body = %q; want %q", name, body, "hello")
+		}
+	}
+
+	// A bytes.Buffer is wrapped and flushed, so the body isn't left in the wrapper.
+	var buf bytes.Buffer
+	n, err := newResponse().WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo = %d; want %d", n, buf.Len())
+	}
+	check("bytes.Buffer", &buf)
+
+	// So is any other writer, such as one end of a pipe.
+	pr, pw := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := newResponse().WriteTo(pw)
+		pw.CloseWithError(err)
+		errc <- err
+	}()
+	check("pipe", pr)
+	if err := <-errc; err != nil {
+		t.Errorf("WriteTo pipe: %v", err)
+	}
+}
+
+func TestResponseError(t *testing.T) {
+	res := http.NewResponse(nil)
+	res.Header.Set("Content-Type", "application/json")
+	res.Error("item not found", http.StatusNotFound)
+
+	if res.StatusCode != http.StatusNotFound || res.Status != "404 Not Found" {
+		t.Errorf("StatusCode, Status = %d, %q; want %d, %q", res.StatusCode, res.Status, http.StatusNotFound, "404 Not Found")
+	}
+	for k, want := range map[string]string{
+		"Content-Type":           "text/plain; charset=utf-8",
+		"X-Content-Type-Options": "nosniff",
+		"Content-Length":         "15",
+	} {
+		if got := res.Header.Get(k); got != want {
+			t.Errorf("%s = %q; want %q", k, got, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := res.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("written StatusCode = %d; want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "item not found\n" {
+		t.Errorf("body = %q; want %q", body, "item not found\n")
+	}
+}
+
+func TestResponseDiff(t *testing.T) {
+	newRes := func() *http.Response {
+		res := http.NewResponse(nil)
+		res.Header.Add("X-A", "1")
+		res.Header.Add("X-B", "2")
+		res.Text("hello")
+		return res
+	}
+	if diffs := newRes().Diff(newRes()); len(diffs) != 0 {
+		t.Errorf("identical responses: Diff = %q; want none", diffs)
+	}
+
+	// Header keys set in another order are the same.
+	r1, r2 := newRes(), http.NewResponse(nil)
+	r2.Text("hello")
+	r2.Header.Add("X-B", "2")
+	r2.Header.Add("X-A", "1")
+	if diffs := r1.Diff(r2); len(diffs) != 0 {
+		t.Errorf("reordered header: Diff = %q; want none", diffs)
+	}
+
+	r1, r2 = newRes(), newRes()
+	r2.SetStatus(http.StatusNotFound)
+	r2.Header.Del("X-A")
+	r2.Body = io.NopCloser(strings.NewReader("world"))
+	want := []string{
+		`StatusLine: "HTTP/1.1 200 OK" != "HTTP/1.1 404 Not Found"`,
+		`Header[X-A]: ["1"] != []`,
+		`Body: "hello" != "world"`,
+	}
+	if diffs := r1.Diff(r2); !reflect.DeepEqual(diffs, want) {
+		t.Errorf("Diff = %q; want %q", diffs, want)
+	}
+}
+
+func TestResponseRetryAfter(t *testing.T) {
+	inAnHour := time.Now().Add(time.Hour).UTC()
+	tests := []struct {
+		value string
+		want  time.Duration // for dates, the wait is at most want and within a minute of it
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{"0", 0, true},
+		{inAnHour.Format(http.TimeFormat), time.Hour, true},
+		{inAnHour.Format(time.RFC850), time.Hour, true},
+		{inAnHour.Format(time.ANSIC), time.Hour, true},
+		{"Sun, 06 Nov 1994 08:49:37 GMT", 0, true}, // in the past
+		{"", 0, false},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		res := http.NewResponse(nil)
+		if tt.value != "" {
+			res.Header.Set("Retry-After", tt.value)
+		}
+		d, ok := res.RetryAfter()
+		if ok != tt.ok || d > tt.want || d < tt.want-time.Minute && tt.want > 0 {
+			t.Errorf("RetryAfter(%q) = %v, %v; want %v, %v", tt.value, d, ok, tt.want, tt.ok)
+		}
+	}
+}
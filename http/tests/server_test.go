@@ -1,10 +1,21 @@
 package tests
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -52,6 +63,143 @@ func TestServerRun(t *testing.T) {
 	fmt.Println(buf.String())
 }
 
+func TestServerRunContext(t *testing.T) {
+	// Find a free port for the server to listen on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	server := http.NewServer("tcp", addr)
+	server.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- server.RunContext(ctx) }()
+
+	// Wait for the server to accept connections.
+	var conn net.Conn
+	for i := 0; i < 100; i++ {
+		if conn, err = net.Dial("tcp", addr); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunContext = %v; want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunContext didn't return after the context was canceled")
+	}
+	if !server.IsShutdown() {
+		t.Error("server isn't shut down after RunContext returned")
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("server accepted a connection after RunContext returned")
+	}
+}
+
+func TestServerRunContextWaitsForConns(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := http.NewServer("tcp", addr)
+	server.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	server.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("hello"))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- server.RunContext(ctx) }()
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		t.Helper()
+		var conn net.Conn
+		var err error
+		for i := 0; i < 100; i++ {
+			if conn, err = net.Dial("tcp", addr); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return conn, bufio.NewReader(conn)
+	}
+
+	// An idle keep-alive connection, which has been served a request.
+	idle, idleBr := dial()
+	defer idle.Close()
+	io.WriteString(idle, "GET / HTTP/1.1\r\nHost: "+addr+"\r\n\r\n")
+	resp, err := http.ReadResponse(idleBr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+
+	// A connection whose request is being served.
+	busy, busyBr := dial()
+	defer busy.Close()
+	io.WriteString(busy, "GET /slow HTTP/1.1\r\nHost: "+addr+"\r\n\r\n")
+	<-started
+
+	cancel()
+	if _, err := idleBr.ReadByte(); err != io.EOF {
+		t.Errorf("reading idle connection = %v; want %v", err, io.EOF)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("RunContext = %v before the request being served finished", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	resp, err = http.ReadResponse(busyBr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "hello" {
+		t.Errorf("body = %q; want %q", body, "hello")
+	}
+	if resp.Header.Get("Connection") != "close" {
+		t.Error("response during shutdown doesn't close the connection")
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunContext = %v; want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunContext didn't return after the request being served finished")
+	}
+}
+
 func TestServerRunWithPipe(t *testing.T) {
 	// Server
 	server := http.NewServer("tcp", "localhost:8080") // create server
@@ -123,3 +271,1418 @@ func TestServerClientPipe(t *testing.T) {
 
 	time.Sleep(2 * time.Second)
 }
+
+// newTestServer starts a server on a local port serving handler and returns its address.
+// The listener is closed when the test finishes.
+func newTestServer(t *testing.T, handler func(http.ResponseWriter, *http.Request)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := http.NewServer("tcp", ln.Addr().String())
+	server.HandleFunc("/", handler)
+	go server.Serve(ln)
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().String()
+}
+
+// registerDefaultMux registers the handler of TestServerNilHandler on the
+// global DefaultServeMux once, so the test can run with -count > 1.
+var registerDefaultMux sync.Once
+
+func TestServerNilHandler(t *testing.T) {
+	registerDefaultMux.Do(func() {
+		http.Handle("/default-mux", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "from DefaultServeMux")
+		}))
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// A zero Server has no Handler, Logger, or timeouts.
+	server := &http.Server{}
+	go server.Serve(ln)
+
+	// The connection is kept alive, so the second request is only served
+	// if the zero timeouts didn't set deadlines that already passed.
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	for i := 0; i < 2; i++ {
+		io.WriteString(conn, "GET /default-mux HTTP/1.1\r\nHost: "+ln.Addr().String()+"\r\n\r\n")
+		resp, err := http.ReadResponse(br)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if body, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || string(body) != "from DefaultServeMux" {
+			t.Errorf("request %d: got %d %q; want %d %q", i, resp.StatusCode, body, http.StatusOK, "from DefaultServeMux")
+		}
+	}
+}
+
+func TestServerValidateHost(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	server := http.NewServer("tcp", ln.Addr().String())
+	allowed := map[string]bool{"example.com": true, "www.example.com": true}
+	server.ValidateHost = func(host string) bool {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		return allowed[host]
+	}
+	var served []string
+	var mu sync.Mutex
+	server.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		served = append(served, r.Host)
+		mu.Unlock()
+		io.WriteString(w, "hello")
+	})
+	go server.Serve(ln)
+
+	tests := []struct {
+		host string
+		code int
+	}{
+		{"example.com", http.StatusOK},
+		{"www.example.com:8080", http.StatusOK},
+		{"attacker.example", http.StatusMisdirectedRequest},
+		{ln.Addr().String(), http.StatusMisdirectedRequest},
+	}
+	for _, tt := range tests {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+tt.host+"\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn))
+		if err != nil {
+			conn.Close()
+			t.Fatalf("%s: %v", tt.host, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		conn.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s: StatusCode = %d; want %d", tt.host, resp.StatusCode, tt.code)
+		}
+		if tt.code == http.StatusMisdirectedRequest && resp.Header.Get("Connection") != "close" {
+			t.Errorf("%s: Connection = %q; want %q", tt.host, resp.Header.Get("Connection"), "close")
+		}
+		if tt.code == http.StatusOK && string(body) != "hello" {
+			t.Errorf("%s: body = %q; want %q", tt.host, body, "hello")
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"example.com", "www.example.com:8080"}; !reflect.DeepEqual(served, want) {
+		t.Errorf("handler served hosts %q; want %q", served, want)
+	}
+}
+
+func TestServerKeepAlive(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+
+	tests := []struct {
+		proto      string
+		connection string
+		keepAlive  bool
+	}{
+		{"HTTP/1.0", "", false},
+		{"HTTP/1.0", "keep-alive", true},
+		{"HTTP/1.1", "", true},
+		{"HTTP/1.1", "close", false},
+	}
+	for _, tt := range tests {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		br := bufio.NewReader(conn)
+
+		for i := 0; i < 2; i++ {
+			req := "GET / " + tt.proto + "\r\nHost: " + addr + "\r\n"
+			if tt.connection != "" {
+				req += "Connection: " + tt.connection + "\r\n"
+			}
+			if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+				if i == 1 && !tt.keepAlive {
+					break
+				}
+				t.Fatalf("%s %q: request %d: %v", tt.proto, tt.connection, i, err)
+			}
+			resp, err := http.ReadResponse(br)
+			if i == 1 && !tt.keepAlive {
+				if err == nil {
+					t.Errorf("%s %q: connection was kept alive; want closed", tt.proto, tt.connection)
+				}
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s %q: response %d: %v", tt.proto, tt.connection, i, err)
+			}
+			if body, _ := io.ReadAll(resp.Body); string(body) != "hello" {
+				t.Errorf("%s %q: body = %q; want %q", tt.proto, tt.connection, body, "hello")
+			}
+			if closed := resp.Header.Get("Connection") == "close"; closed == tt.keepAlive {
+				t.Errorf("%s %q: Connection header = %q; keep-alive %v", tt.proto, tt.connection, resp.Header.Get("Connection"), tt.keepAlive)
+			}
+		}
+		conn.Close()
+	}
+}
+
+func TestServerPipelining(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Mode") == "skip-body" {
+			// The unread body is discarded before the next request is read.
+			w.Write([]byte(r.Header.Get("X-Seq") + ":"))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.Header.Get("X-Seq") + ":" + string(body)))
+	})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// All three requests are sent before any response is read.
+	post := "POST / HTTP/1.1\r\nHost: " + addr + "\r\n"
+	reqs := post + "X-Seq: 1\r\nContent-Length: 5\r\n\r\nfirst" +
+		post + "X-Seq: 2\r\nX-Mode: skip-body\r\nContent-Length: 7\r\n\r\nskipped" +
+		"GET / HTTP/1.1\r\nHost: " + addr + "\r\nX-Seq: 3\r\n\r\n"
+	if _, err := io.WriteString(conn, reqs); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	for _, want := range []string{"1:first", "2:", "3:"} {
+		resp, err := http.ReadResponse(br)
+		if err != nil {
+			t.Fatalf("response %q: %v", want, err)
+		}
+		if body, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || string(body) != want {
+			t.Errorf("got %d %q; want 200 %q", resp.StatusCode, body, want)
+		}
+	}
+}
+
+func TestServerHTTP2Preface(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called for an HTTP/2 preface")
+	})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+
+	// The server answers in HTTP/2 with an empty SETTINGS frame and a GOAWAY
+	// frame with the error code HTTP_1_1_REQUIRED, then closes the connection.
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\x00\x00\x00\x04\x00\x00\x00\x00\x00" +
+		"\x00\x00\x08\x07\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0d"
+	if string(got) != want {
+		t.Errorf("server replied %q; want %q", got, want)
+	}
+}
+
+func TestServerConnectionHeader(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Mode") {
+		case "flush":
+			// The head is written before the handler returns.
+			w.Header().Set("Content-Length", "5")
+			w.Write([]byte("hello"))
+			w.(http.Flusher).Flush()
+		case "upgrade":
+			w.Header().Set("Upgrade", "h2c")
+			w.WriteHeader(http.StatusUpgradeRequired)
+		default:
+			w.Write([]byte("hello"))
+		}
+	})
+
+	tests := []struct {
+		mode       string
+		proto      string
+		connection string
+		want       string
+	}{
+		{"", "HTTP/1.1", "", ""},
+		{"", "HTTP/1.1", "close", "close"},
+		{"", "HTTP/1.0", "", "close"},
+		{"", "HTTP/1.0", "keep-alive", "keep-alive"},
+		{"flush", "HTTP/1.1", "", ""},
+		{"flush", "HTTP/1.1", "close", "close"},
+		{"flush", "HTTP/1.0", "keep-alive", "keep-alive"},
+		{"upgrade", "HTTP/1.1", "close", "Upgrade, close"},
+	}
+	for _, tt := range tests {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		req := "GET / " + tt.proto + "\r\nHost: " + addr + "\r\nX-Mode: " + tt.mode + "\r\n"
+		if tt.connection != "" {
+			req += "Connection: " + tt.connection + "\r\n"
+		}
+		io.WriteString(conn, req+"\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn))
+		conn.Close()
+		if err != nil {
+			t.Fatalf("%q %s %q: %v", tt.mode, tt.proto, tt.connection, err)
+		}
+		if got := resp.Header.Get("Connection"); got != tt.want {
+			t.Errorf("%q %s %q: Connection = %q; want %q", tt.mode, tt.proto, tt.connection, got, tt.want)
+		}
+	}
+}
+
+func TestServerNoBodyStatus(t *testing.T) {
+	writeErrs := make(chan error, 3)
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if code, _ := strconv.Atoi(r.Header.Get("X-Status")); code != 0 {
+			w.Header().Set("Content-Length", "5")
+			w.WriteHeader(code)
+		}
+		_, err := w.Write([]byte("hello"))
+		writeErrs <- err
+		if r.Header.Get("X-Flush") != "" {
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	br := bufio.NewReader(conn)
+	tests := []struct {
+		status   string
+		flush    bool
+		wantCode int
+		wantErr  error
+	}{
+		{"204", false, http.StatusNoContent, http.ErrBodyNotAllowed},
+		{"304", true, http.StatusNotModified, http.ErrBodyNotAllowed},
+		// Had a body been sent above, this response would be misread.
+		{"", false, http.StatusOK, nil},
+	}
+	for _, tt := range tests {
+		req := "GET / HTTP/1.1\r\nHost: " + addr + "\r\nX-Status: " + tt.status + "\r\n"
+		if tt.flush {
+			req += "X-Flush: 1\r\n"
+		}
+		io.WriteString(conn, req+"\r\n")
+		resp, err := http.ReadResponse(br)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.status, err)
+		}
+		if resp.StatusCode != tt.wantCode {
+			t.Errorf("%q: StatusCode = %d; want %d", tt.status, resp.StatusCode, tt.wantCode)
+		}
+		if err := <-writeErrs; err != tt.wantErr {
+			t.Errorf("%q: Write error = %v; want %v", tt.status, err, tt.wantErr)
+		}
+		if tt.wantCode == http.StatusOK {
+			if body, _ := io.ReadAll(resp.Body); string(body) != "hello" {
+				t.Errorf("%q: body = %q; want %q", tt.status, body, "hello")
+			}
+			continue
+		}
+		for _, k := range []string{"Content-Length", "Transfer-Encoding"} {
+			if http.HeaderHas(resp.Header, k) {
+				t.Errorf("%q: %s = %q; want none", tt.status, k, resp.Header.Get(k))
+			}
+		}
+	}
+}
+
+func TestServerDiscardsUnreadBody(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.Header.Get("X-Seq")))
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	br := bufio.NewReader(conn)
+
+	reqs := []struct {
+		raw  string
+		want string
+	}{
+		{"POST / HTTP/1.1\r\nHost: " + addr + "\r\nX-Seq: 1\r\nContent-Length: 11\r\n\r\nGET /bogus ", "POST 1"},
+		{"POST / HTTP/1.1\r\nHost: " + addr + "\r\nX-Seq: 2\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n", "POST 2"},
+		{"GET / HTTP/1.1\r\nHost: " + addr + "\r\nX-Seq: 3\r\n\r\n", "GET 3"},
+	}
+	for _, tt := range reqs {
+		if _, err := io.WriteString(conn, tt.raw); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.ReadResponse(br)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.want, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != tt.want {
+			t.Errorf("body = %q; want %q", body, tt.want)
+		}
+		if c := resp.Header.Get("Connection"); c == "close" {
+			t.Errorf("%s: Connection = %q; want connection kept alive", tt.want, c)
+		}
+	}
+}
+
+func TestServerPooledBuffersReset(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Seq")))
+	})
+
+	for i := 0; i < 5; i++ {
+		// Leave a partial request in the connection's read buffer.
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\nX-Seq: stale\r\n")
+		conn.Close()
+
+		conn, err = net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		seq := fmt.Sprint(i)
+		io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\nX-Seq: "+seq+"\r\nConnection: close\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn))
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != seq {
+			t.Errorf("request %d: body = %q; want %q", i, body, seq)
+		}
+		conn.Close()
+	}
+}
+
+func BenchmarkServerConnections(b *testing.B) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ln.Close()
+	server := http.NewServer("tcp", ln.Addr().String())
+	server.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	go server.Serve(ln)
+
+	req := "GET / HTTP/1.1\r\nHost: " + ln.Addr().String() + "\r\nConnection: close\r\n\r\n"
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.WriteString(conn, req)
+		io.Copy(io.Discard, conn)
+		conn.Close()
+	}
+}
+
+func TestServerFileBody(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<16) // 1 MB
+	name := filepath.Join(t.TempDir(), "large.bin")
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.(interface{ File(string) }).File(name)
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\nConnection: close\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ContentLength != int64(len(data)) {
+		t.Errorf("ContentLength = %d; want %d", resp.ContentLength, len(data))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, data) {
+		t.Errorf("body differs from file: got %d bytes; want %d", len(body), len(data))
+	}
+}
+
+func TestServerExpectContinue(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Reject") != "" {
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return conn, bufio.NewReader(conn)
+	}
+	head := "POST / HTTP/1.1\r\nHost: " + addr + "\r\nContent-Length: 5\r\nExpect: 100-continue\r\n"
+
+	t.Run("Accept", func(t *testing.T) {
+		conn, br := dial()
+		defer conn.Close()
+		io.WriteString(conn, head+"\r\n")
+		if line, err := br.ReadString('\n'); err != nil || line != "HTTP/1.1 100 Continue\r\n" {
+			t.Fatalf("interim response line = %q, %v; want 100 Continue", line, err)
+		}
+		br.ReadString('\n') // blank line ending the interim response
+		io.WriteString(conn, "hello")
+		resp, err := http.ReadResponse(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(resp.Body); resp.StatusCode != 200 || string(body) != "hello" {
+			t.Errorf("got %d %q; want 200 %q", resp.StatusCode, body, "hello")
+		}
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		conn, br := dial()
+		defer conn.Close()
+		// The body is never sent.
+		io.WriteString(conn, head+"X-Reject: 1\r\n\r\n")
+		resp, err := http.ReadResponse(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusExpectationFailed {
+			t.Errorf("StatusCode = %d; want %d", resp.StatusCode, http.StatusExpectationFailed)
+		}
+		if c := resp.Header.Get("Connection"); c != "close" {
+			t.Errorf("Connection = %q; want %q", c, "close")
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		conn, br := dial()
+		defer conn.Close()
+		io.WriteString(conn, "POST / HTTP/1.1\r\nHost: "+addr+"\r\nContent-Length: 5\r\nExpect: something-else\r\n\r\n")
+		resp, err := http.ReadResponse(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusExpectationFailed {
+			t.Errorf("StatusCode = %d; want %d", resp.StatusCode, http.StatusExpectationFailed)
+		}
+	})
+}
+
+// recordLog is a Log that records its warnings.
+type recordLog struct {
+	mu    sync.Mutex
+	warns []string
+}
+
+func (l *recordLog) Status(path, method, remoteAddress string) {}
+func (l *recordLog) Fatal(error)                               {}
+func (l *recordLog) Info(string)                               {}
+func (l *recordLog) Warn(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warns = append(l.warns, msg)
+}
+
+func TestSlowRequestLogger(t *testing.T) {
+	log := new(recordLog)
+	slowLogger := http.SlowRequestLogger(20*time.Millisecond, log)
+	sleep := func(d time.Duration) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { time.Sleep(d) })
+	}
+
+	req, err := http.NewRequest("GET", "http://example.com/fast", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slowLogger(sleep(0)).ServeHTTP(nil, req)
+	if len(log.warns) != 0 {
+		t.Errorf("logged %q for a request under the threshold; want nothing", log.warns)
+	}
+
+	req, err = http.NewRequest("POST", "http://example.com/reports", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slowLogger(sleep(50*time.Millisecond)).ServeHTTP(nil, req)
+	if len(log.warns) != 1 || !strings.HasPrefix(log.warns[0], "slow request: POST /reports took ") {
+		t.Errorf("logged %q; want one line for POST /reports", log.warns)
+	}
+}
+
+func TestMethodOverride(t *testing.T) {
+	var got string
+	h := http.MethodOverride(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Method
+	}))
+	tests := []struct {
+		method   string
+		override string // X-HTTP-Method-Override header
+		form     string // urlencoded body
+		want     string
+	}{
+		{"POST", "DELETE", "", "DELETE"},
+		{"POST", "patch", "", "PATCH"},
+		{"POST", "", "_method=put&name=x", "PUT"},
+		{"POST", "DELETE", "_method=put", "DELETE"}, // the header wins
+		{"POST", "", "name=x", "POST"},
+		{"POST", "GET", "", "POST"},     // can't tunnel a safe method
+		{"POST", "CONNECT", "", "POST"}, // nor anything other than PUT, PATCH, and DELETE
+		{"GET", "DELETE", "", "GET"},    // only POST requests are overridden
+		{"PUT", "DELETE", "", "PUT"},
+	}
+	for _, tt := range tests {
+		var body io.Reader
+		if tt.form != "" {
+			body = strings.NewReader(tt.form)
+		}
+		req, err := http.NewRequest(tt.method, "http://example.com/items/1", nil, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.override != "" {
+			req.Header.Set("X-HTTP-Method-Override", tt.override)
+		}
+		if tt.form != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		got = ""
+		h.ServeHTTP(nil, req)
+		if got != tt.want {
+			t.Errorf("%s with override %q and form %q: handler got %s; want %s", tt.method, tt.override, tt.form, got, tt.want)
+		}
+		if req.Method != tt.method {
+			t.Errorf("%s with override %q and form %q: original request's method changed to %s", tt.method, tt.override, tt.form, req.Method)
+		}
+	}
+
+	// A multipart body isn't parsed, so the handler can still stream it.
+	var part string
+	h = http.MethodOverride(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Method
+		mr, err := r.MultipartReader()
+		if err != nil {
+			t.Errorf("MultipartReader: %v", err)
+			return
+		}
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Errorf("NextPart: %v", err)
+			return
+		}
+		b, _ := io.ReadAll(p)
+		part = p.FormName() + "=" + string(b)
+	}))
+	body := "--b\r\nContent-Disposition: form-data; name=\"_method\"\r\n\r\nput\r\n--b--\r\n"
+	req, err := http.NewRequest("POST", "http://example.com/items/1", nil, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+	h.ServeHTTP(nil, req)
+	if got != "POST" || part != "_method=put" {
+		t.Errorf("multipart POST: handler got %s with part %q; want POST with %q", got, part, "_method=put")
+	}
+}
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	auth := http.BasicAuthMiddleware(`admin "area"`, func(user, pass string) bool {
+		return user == "aladdin" && pass == "open sesame"
+	})
+	h := http.ToStdHandler(auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		io.WriteString(w, "hello "+user)
+	})))
+	basic := func(creds string) string { return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds)) }
+
+	tests := []struct {
+		name          string
+		authorization string
+		code          int
+	}{
+		{"valid", basic("aladdin:open sesame"), http.StatusOK},
+		{"lowercase scheme", "basic " + basic("aladdin:open sesame")[len("Basic "):], http.StatusOK},
+		{"wrong password", basic("aladdin:letmein"), http.StatusUnauthorized},
+		{"missing", "", http.StatusUnauthorized},
+		{"no colon", basic("aladdin"), http.StatusUnauthorized},
+		{"bad base64", "Basic !!!", http.StatusUnauthorized},
+		{"other scheme", "Bearer token", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s: StatusCode = %d; want %d", tt.name, rec.Code, tt.code)
+			continue
+		}
+		challenge := rec.Header().Get("WWW-Authenticate")
+		if tt.code == http.StatusOK {
+			if body := rec.Body.String(); body != "hello aladdin" {
+				t.Errorf("%s: body = %q; want %q", tt.name, body, "hello aladdin")
+			}
+			if challenge != "" {
+				t.Errorf("%s: WWW-Authenticate = %q; want none", tt.name, challenge)
+			}
+		} else if want := `Basic realm="admin \"area\""`; challenge != want {
+			t.Errorf("%s: WWW-Authenticate = %q; want %q", tt.name, challenge, want)
+		}
+	}
+}
+
+func TestTimeoutHandler(t *testing.T) {
+	// The slow handler writes as soon as it sees its context done, racing the
+	// timeout response, and again once the client has read that response.
+	writeErrs := make(chan error, 2)
+	replied := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		_, err := w.Write([]byte("too late"))
+		writeErrs <- err
+		<-replied
+		_, err = w.Write([]byte("much too late"))
+		writeErrs <- err
+	})
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Fast", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("fast"))
+	})
+
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		wantCode   int
+		wantBody   string
+		wantHeader string
+	}{
+		{"Slow", http.TimeoutHandler(slow, 50*time.Millisecond, "timed out"), http.StatusServiceUnavailable, "timed out", ""},
+		{"Fast", http.TimeoutHandler(fast, time.Second, "timed out"), http.StatusCreated, "fast", "1"},
+	}
+	for _, tt := range tests {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := http.NewServer("tcp", ln.Addr().String())
+		server.Handler = tt.handler
+		go server.Serve(ln)
+
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			ln.Close()
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		ln.Close()
+		if resp.StatusCode != tt.wantCode || string(body) != tt.wantBody {
+			t.Errorf("%s: got %d %q; want %d %q", tt.name, resp.StatusCode, body, tt.wantCode, tt.wantBody)
+		}
+		if got := resp.Header.Get("X-Fast"); got != tt.wantHeader {
+			t.Errorf("%s: X-Fast = %q; want %q", tt.name, got, tt.wantHeader)
+		}
+	}
+	close(replied)
+
+	for _, when := range []string{"once the context is done", "after the timeout response"} {
+		select {
+		case err := <-writeErrs:
+			if err != http.ErrHandlerTimeout {
+				t.Errorf("Write %s = %v; want %v", when, err, http.ErrHandlerTimeout)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("slow handler did not return")
+		}
+	}
+}
+
+func TestTimeoutHandlerBody(t *testing.T) {
+	// The handler is still reading the body, of which only part was sent,
+	// when it times out.
+	readErrs := make(chan error, 1)
+	handler := http.TimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		readErrs <- err
+	}), 50*time.Millisecond, "timed out")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	server := http.NewServer("tcp", ln.Addr().String())
+	server.Handler = handler
+	go server.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "POST / HTTP/1.1\r\nHost: foo\r\nContent-Length: 10\r\n\r\nhello")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusServiceUnavailable || string(body) != "timed out" {
+		t.Errorf("got %d %q; want %d %q", resp.StatusCode, body, http.StatusServiceUnavailable, "timed out")
+	}
+	if c := resp.Header.Get("Connection"); c != "close" {
+		t.Errorf("Connection = %q; want %q", c, "close")
+	}
+	select {
+	case err := <-readErrs:
+		if err == nil {
+			t.Error("handler read the body after its timeout without error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler still reading the body")
+	}
+
+	// The rest of the body isn't taken for another request.
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n")
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("read after timeout response = %v; want connection closed", err)
+	}
+}
+
+func TestServerBadRequest(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\nX-Folded: first\r\n second\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("StatusCode = %d; want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if c := resp.Header.Get("Connection"); c != "close" {
+		t.Errorf("Connection = %q; want %q", c, "close")
+	}
+}
+
+func TestServerParseErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		code int
+	}{
+		{"bad request line", "GET /\r\nHost: pipe\r\n\r\n", http.StatusBadRequest},
+		{"bad request target", "GET foo HTTP/1.1\r\nHost: pipe\r\n\r\n", http.StatusBadRequest},
+		{"bad header line", "GET / HTTP/1.1\r\nHost pipe\r\n\r\n", http.StatusBadRequest},
+		{"malformed version", "GET / HTTP/x.y\r\nHost: pipe\r\n\r\n", http.StatusBadRequest},
+		{"unsupported version", "GET / HTTP/2.0\r\nHost: pipe\r\n\r\n", http.StatusHTTPVersionNotSupported},
+		{"differing Content-Length", "POST / HTTP/1.1\r\nHost: pipe\r\nContent-Length: 3\r\nContent-Length: 4\r\n\r\nabcd", http.StatusBadRequest},
+		{"Transfer-Encoding split over lines", "POST / HTTP/1.1\r\nHost: pipe\r\nTransfer-Encoding: gzip\r\nTransfer-Encoding: chunked\r\nContent-Length: 3\r\n\r\n5\r\nhello\r\n0\r\n\r\n", http.StatusNotImplemented},
+		{"unknown Transfer-Encoding", "POST / HTTP/1.1\r\nHost: pipe\r\nTransfer-Encoding: xchunked\r\nContent-Length: 3\r\n\r\nabc", http.StatusNotImplemented},
+		{"chunked not final", "POST / HTTP/1.1\r\nHost: pipe\r\nTransfer-Encoding: chunked\r\nTransfer-Encoding: identity\r\nContent-Length: 3\r\n\r\nabc", http.StatusNotImplemented},
+		{"chunked twice", "POST / HTTP/1.1\r\nHost: pipe\r\nTransfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n", http.StatusBadRequest},
+		{"Transfer-Encoding on HTTP/1.0", "POST / HTTP/1.0\r\nHost: pipe\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n", http.StatusBadRequest},
+		{"duplicate Host", "GET / HTTP/1.1\r\nHost: pipe\r\nHost: other\r\n\r\n", http.StatusBadRequest},
+		{"header too large", "GET / HTTP/1.1\r\nHost: pipe\r\nX-Large: " + strings.Repeat("x", 8<<10) + "\r\n\r\n", http.StatusRequestHeaderFieldsTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, conn := newPipeListener()
+			defer ln.Close()
+			defer conn.Close()
+			server := http.NewServer("tcp", "pipe")
+			server.MaxHeaderBytes = 1 << 10
+			go server.Serve(ln)
+
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			// The server may stop reading before the whole request was written.
+			go io.WriteString(conn, tt.raw)
+			resp, err := http.ReadResponse(bufio.NewReader(conn))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.code {
+				t.Errorf("StatusCode = %d; want %d", resp.StatusCode, tt.code)
+			}
+			if c := resp.Header.Get("Connection"); c != "close" {
+				t.Errorf("Connection = %q; want %q", c, "close")
+			}
+		})
+	}
+}
+
+func TestServerHeadResponse(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("x"), 100))
+	})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "HEAD / HTTP/1.1\r\nHost: "+addr+"\r\n\r\n")
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\nConnection: close\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cl := resp.Header.Get("Content-Length"); cl != "100" {
+		t.Errorf("HEAD Content-Length = %q; want %q", cl, "100")
+	}
+	// No body follows the head, so the next response starts right after it.
+	resp, err = http.ReadResponse(br)
+	if err != nil {
+		t.Fatalf("reading response after HEAD: %v", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); len(body) != 100 {
+		t.Errorf("GET body length = %d; want 100", len(body))
+	}
+	if rest, _ := io.ReadAll(br); len(rest) != 0 {
+		t.Errorf("unexpected trailing bytes %q", rest)
+	}
+}
+
+func TestServerClientDisconnect(t *testing.T) {
+	ctxc := make(chan context.Context, 1)
+	large := bytes.Repeat([]byte("x"), 16<<20)
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Large") != "" {
+			ctxc <- r.Context()
+			w.Write(large)
+			return
+		}
+		w.Write([]byte("hello"))
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\nX-Large: 1\r\n\r\n")
+	if _, err := io.ReadFull(conn, make([]byte, 1024)); err != nil {
+		t.Fatal(err)
+	}
+	// Go away mid-response, resetting the connection.
+	conn.(*net.TCPConn).SetLinger(0)
+	conn.Close()
+
+	ctx := <-ctxc
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("request context not canceled after client disconnect")
+	}
+	if cause := context.Cause(ctx); cause == nil || cause == context.Canceled {
+		t.Errorf("context cause = %v; want the write error", cause)
+	}
+
+	// The server keeps serving other connections.
+	conn, err = net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "hello" {
+		t.Errorf("body = %q; want %q", body, "hello")
+	}
+}
+
+func TestRequestDone(t *testing.T) {
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Done():
+			done <- context.Cause(r.Context())
+		case <-time.After(5 * time.Second):
+			done <- errors.New("Done channel not closed")
+		}
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\n\r\n")
+	<-started
+	conn.Close()
+
+	if err := <-done; err == nil || err == context.Canceled {
+		t.Errorf("context cause = %v; want the read error of the closed connection", err)
+	}
+}
+
+func TestServerClientGoneContextDone(t *testing.T) {
+	for _, raw := range []string{
+		"GET / HTTP/1.1\r\nHost: foo\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: foo\r\nContent-Length: 5\r\n\r\nhello",
+		"POST / HTTP/1.1\r\nHost: foo\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n",
+	} {
+		started := make(chan struct{})
+		done := make(chan error, 1)
+		addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+			close(started)
+			// Nothing is written, so only reading the connection can
+			// tell the client went away.
+			select {
+			case <-r.Context().Done():
+				done <- context.Cause(r.Context())
+			case <-time.After(5 * time.Second):
+				done <- nil
+			}
+		})
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(conn, raw)
+		<-started
+		conn.Close()
+		if err := <-done; err == nil {
+			t.Errorf("%q: request context not done after the client went away", raw)
+		} else if err == context.Canceled {
+			t.Errorf("%q: context cause = %v; want the read error of the closed connection", raw, err)
+		}
+	}
+}
+
+func TestServerContentLengthExceeded(t *testing.T) {
+	errc := make(chan error, 1)
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		w.Write([]byte("hel"))
+		_, err := w.Write([]byte("lo, world"))
+		errc <- err
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrContentLength) {
+		t.Errorf("Write past Content-Length = %v; want %v", err, http.ErrContentLength)
+	}
+	// The handler wrote only 3 of the 5 declared bytes, so the server
+	// must close the connection; otherwise the read hits the deadline.
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "hel" {
+		t.Errorf("body = %q, %v; want %q", body, err, "hel")
+	}
+}
+
+func TestResponseWriterWriteString(t *testing.T) {
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		sw, ok := w.(io.StringWriter)
+		if !ok {
+			t.Error("ResponseWriter doesn't implement io.StringWriter")
+			return
+		}
+		sw.WriteString("hello, ")
+		w.Write([]byte("world"))
+		io.WriteString(w, "!")
+	})
+	resp, err := http.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "hello, world!" {
+		t.Errorf("body = %q; want %q", body, "hello, world!")
+	}
+	if resp.ContentLength != int64(len("hello, world!")) {
+		t.Errorf("ContentLength = %d; want %d", resp.ContentLength, len("hello, world!"))
+	}
+}
+
+func BenchmarkResponseWriterWriteString(b *testing.B) {
+	const s = "a string long enough that converting it to a []byte allocates\n"
+	bench := func(b *testing.B, write func(w http.ResponseWriter)) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer ln.Close()
+		server := http.NewServer("tcp", ln.Addr().String())
+		server.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < 100; i++ {
+				write(w)
+			}
+		})
+		go server.Serve(ln)
+
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		req := "GET / HTTP/1.1\r\nHost: " + ln.Addr().String() + "\r\n\r\n"
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			io.WriteString(conn, req)
+			resp, err := http.ReadResponse(br)
+			if err != nil {
+				b.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+		}
+	}
+	b.Run("WriteString", func(b *testing.B) {
+		bench(b, func(w http.ResponseWriter) { w.(io.StringWriter).WriteString(s) })
+	})
+	b.Run("Write", func(b *testing.B) {
+		bench(b, func(w http.ResponseWriter) { w.Write([]byte(s)) })
+	})
+}
+
+func TestServerFlush(t *testing.T) {
+	proceed := make(chan struct{})
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first")
+		w.(http.Flusher).Flush()
+		if r.Proto == "HTTP/1.1" {
+			<-proceed // the client must see the first write before the handler finishes
+		}
+		io.WriteString(w, "second")
+	})
+
+	t.Run("Chunked", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		br := bufio.NewReader(conn)
+		io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\n\r\n")
+		resp, err := http.ReadResponse(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if te := resp.Header.Get("Transfer-Encoding"); te != "chunked" {
+			t.Errorf("Transfer-Encoding = %q; want %q", te, "chunked")
+		}
+		first := make([]byte, len("first"))
+		if _, err := io.ReadFull(resp.Body, first); err != nil || string(first) != "first" {
+			t.Fatalf("first read = %q, %v; want %q", first, err, "first")
+		}
+		close(proceed)
+		if rest, _ := io.ReadAll(resp.Body); string(rest) != "second" {
+			t.Errorf("rest of body = %q; want %q", rest, "second")
+		}
+
+		// The chunked response leaves the connection usable.
+		io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+addr+"\r\n\r\n")
+		resp, err = http.ReadResponse(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != "firstsecond" {
+			t.Errorf("second response body = %q; want %q", body, "firstsecond")
+		}
+	})
+
+	t.Run("HTTP/1.0", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		io.WriteString(conn, "GET / HTTP/1.0\r\nHost: "+addr+"\r\nConnection: keep-alive\r\n\r\n")
+		raw, err := io.ReadAll(conn) // the body ends when the connection is closed
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(raw), "Connection: close\r\n") || !strings.HasSuffix(string(raw), "\r\n\r\nfirstsecond") {
+			t.Errorf("response = %q; want Connection: close and body %q", raw, "firstsecond")
+		}
+		if strings.Contains(string(raw), "Transfer-Encoding") {
+			t.Errorf("response = %q; want no Transfer-Encoding for HTTP/1.0", raw)
+		}
+	})
+}
+
+// pipeListener is a net.Listener that accepts a single connection, the server end of a net.Pipe.
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPipeListener() (*pipeListener, net.Conn) {
+	client, server := net.Pipe()
+	l := &pipeListener{conns: make(chan net.Conn, 1), closed: make(chan struct{})}
+	l.conns <- server
+	return l, client
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr{} }
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+func TestEventStream(t *testing.T) {
+	ln, conn := newPipeListener()
+	defer ln.Close()
+	defer conn.Close()
+	server := http.NewServer("tcp", "pipe")
+	server.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		stream, err := http.NewEventStream(w)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		stream.Send("greeting", "hello")
+		stream.Send("", "line1\nline2")
+		stream.Send("", "x\revent: admin\r\ny")
+	})
+	go server.Serve(ln)
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: pipe\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q; want %q", ct, "text/event-stream")
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "event: greeting\ndata: hello\n\ndata: line1\ndata: line2\n\n" +
+		"data: x\ndata: event: admin\ndata: y\n\n"
+	if string(body) != want {
+		t.Errorf("events = %q; want %q", body, want)
+	}
+}
+
+// sizeRecordingListener wraps the connections it accepts to record
+// the largest read and write made on them.
+type sizeRecordingListener struct {
+	net.Listener
+	mu        sync.Mutex
+	maxRead   int
+	maxWrite  int
+	firstRead int
+}
+
+func (l *sizeRecordingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &sizeRecordingConn{Conn: c, l: l}, nil
+}
+
+type sizeRecordingConn struct {
+	net.Conn
+	l *sizeRecordingListener
+}
+
+func (c *sizeRecordingConn) Read(p []byte) (int, error) {
+	c.l.mu.Lock()
+	if c.l.firstRead == 0 {
+		c.l.firstRead = len(p)
+	}
+	c.l.maxRead = max(c.l.maxRead, len(p))
+	c.l.mu.Unlock()
+	return c.Conn.Read(p)
+}
+
+func (c *sizeRecordingConn) Write(p []byte) (int, error) {
+	c.l.mu.Lock()
+	c.l.maxWrite = max(c.l.maxWrite, len(p))
+	c.l.mu.Unlock()
+	return c.Conn.Write(p)
+}
+
+func TestServerBufferSizes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl := &sizeRecordingListener{Listener: ln}
+	defer rl.Close()
+
+	body := strings.Repeat("a", 4096)
+	server := http.NewServer("tcp", ln.Addr().String())
+	server.ReadBufferSize = 256
+	server.WriteBufferSize = 512
+	server.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+	go server.Serve(rl)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n")
+	resp, err := http.ReadResponse(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(resp.Body); string(got) != body {
+		t.Fatalf("body length = %d; want %d", len(got), len(body))
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.firstRead != 256 || rl.maxRead != 256 {
+		t.Errorf("read sizes: first %d, max %d; want 256", rl.firstRead, rl.maxRead)
+	}
+	if rl.maxWrite > 512 {
+		t.Errorf("max write size = %d; want <= 512", rl.maxWrite)
+	}
+}
+
+func TestServerBodyReadContextCanceled(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reading := make(chan struct{})
+	readErr := make(chan error, 1)
+	server := http.NewServer("tcp", ln.Addr().String())
+	server.BaseContext = func(net.Listener) context.Context { return ctx }
+	server.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(r.Body, buf); err != nil {
+			readErr <- err
+			return
+		}
+		close(reading)
+		_, err := io.ReadAll(r.Body) // blocks until the context is canceled
+		readErr <- err
+	})
+	go server.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 10\r\n\r\nhello")
+
+	select {
+	case <-reading:
+	case err := <-readErr:
+		t.Fatalf("reading first part of body: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for handler to read body")
+	}
+	cancel()
+	select {
+	case err := <-readErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Read error = %v; want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read did not return after the context was canceled")
+	}
+}
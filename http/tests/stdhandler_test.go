@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"bufio"
+	"io"
+	"net"
+	libhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	http "github.com/curol/network/http"
+)
+
+func TestFromStdHandler(t *testing.T) {
+	std := libhttp.HandlerFunc(func(w libhttp.ResponseWriter, r *libhttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Query", r.URL.Query().Get("q"))
+		w.WriteHeader(libhttp.StatusCreated)
+		io.WriteString(w, r.Header.Get("X-Greeting")+" "+string(body))
+	})
+	addr := newTestServer(t, http.FromStdHandler(std).ServeHTTP)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "POST /?q=search HTTP/1.1\r\nHost: "+addr+"\r\nX-Greeting: hello\r\nContent-Length: 5\r\n\r\nworld")
+	resp, err := http.ReadResponse(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("StatusCode = %d; want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if got := resp.Header.Get("X-Method"); got != "POST" {
+		t.Errorf("X-Method = %q; want %q", got, "POST")
+	}
+	if got := resp.Header.Get("X-Query"); got != "search" {
+		t.Errorf("X-Query = %q; want %q", got, "search")
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "hello world" {
+		t.Errorf("body = %q; want %q", body, "hello world")
+	}
+}
+
+func TestToStdHandler(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Path", r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, r.Header.Get("X-Greeting")+" "+string(body))
+	})
+	req := httptest.NewRequest("PUT", "http://example.com/items/1", strings.NewReader("world"))
+	req.Header.Set("X-Greeting", "hello")
+	rec := httptest.NewRecorder()
+	http.ToStdHandler(h).ServeHTTP(rec, req)
+
+	if rec.Code != libhttp.StatusAccepted {
+		t.Errorf("Code = %d; want %d", rec.Code, libhttp.StatusAccepted)
+	}
+	if got := rec.Header().Get("X-Path"); got != "/items/1" {
+		t.Errorf("X-Path = %q; want %q", got, "/items/1")
+	}
+	if got := rec.Body.String(); got != "hello world" {
+		t.Errorf("body = %q; want %q", got, "hello world")
+	}
+}
@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	http "github.com/curol/network/http"
+)
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(1994, time.November, 6, 8, 49, 37, 0, time.UTC)
+	tests := []struct {
+		text string
+		ok   bool
+	}{
+		{"Sun, 06 Nov 1994 08:49:37 GMT", true},  // RFC 1123
+		{"Sunday, 06-Nov-94 08:49:37 GMT", true}, // RFC 850
+		{"Sun Nov  6 08:49:37 1994", true},       // ANSI C asctime
+		{"1994-11-06T08:49:37Z", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		got, err := http.ParseTime(tt.text)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("ParseTime(%q): err = %v; want ok %v", tt.text, err, tt.ok)
+			continue
+		}
+		if tt.ok && !got.Equal(want) {
+			t.Errorf("ParseTime(%q) = %v; want %v", tt.text, got, want)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	tm := time.Date(1994, time.November, 6, 9, 49, 37, 0, time.FixedZone("CET", 3600))
+	if got, want := http.FormatTime(tm), "Sun, 06 Nov 1994 08:49:37 GMT"; got != want {
+		t.Errorf("FormatTime = %q; want %q", got, want)
+	}
+}
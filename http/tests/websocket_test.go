@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	http "github.com/curol/network/http"
+)
+
+func TestUpgraderHandshake(t *testing.T) {
+	var upgrader http.Upgrader
+	addr := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := upgrader.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Echo the first "frame" to show the connection is usable after the handshake.
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(brw, buf); err != nil {
+			t.Error(err)
+			return
+		}
+		brw.Write(buf)
+		brw.Flush()
+	})
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return conn, bufio.NewReader(conn)
+	}
+	handshake := "GET / HTTP/1.1\r\nHost: " + addr + "\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n"
+
+	// The key and accept values are the example of RFC 6455, section 1.3.
+	conn, br := dial()
+	defer conn.Close()
+	io.WriteString(conn, handshake+"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\nhello")
+	resp, err := http.ReadResponse(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("StatusCode = %d; want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("Sec-WebSocket-Accept = %q; want %q", got, want)
+	}
+	if got := resp.Header.Get("Upgrade"); got != "websocket" {
+		t.Errorf("Upgrade = %q; want %q", got, "websocket")
+	}
+	echo := make([]byte, 5)
+	if _, err := io.ReadFull(br, echo); err != nil || string(echo) != "hello" {
+		t.Errorf("echo = %q, %v; want %q", echo, err, "hello")
+	}
+
+	// A handshake without a key is rejected.
+	conn2, br2 := dial()
+	defer conn2.Close()
+	io.WriteString(conn2, handshake+"\r\n")
+	resp, err = http.ReadResponse(br2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("StatusCode without key = %d; want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestUpgraderCheckOrigin(t *testing.T) {
+	const key = "Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"
+	handshake := func(addr, origin string) int {
+		t.Helper()
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		req := "GET / HTTP/1.1\r\nHost: " + addr + "\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n" + key
+		if origin != "" {
+			req += "Origin: " + origin + "\r\n"
+		}
+		io.WriteString(conn, req+"\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+	serve := func(upgrader *http.Upgrader) string {
+		return newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if conn, _, err := upgrader.Upgrade(w, r); err == nil {
+				conn.Close()
+			}
+		})
+	}
+
+	// By default only same-origin requests are accepted.
+	addr := serve(&http.Upgrader{})
+	tests := []struct {
+		origin string
+		code   int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{"http://" + addr, http.StatusSwitchingProtocols},
+		{"https://" + strings.ToUpper(addr), http.StatusSwitchingProtocols},
+		{"http://evil.example", http.StatusForbidden},
+		{"http://" + addr + ".evil.example", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if code := handshake(addr, tt.origin); code != tt.code {
+			t.Errorf("Origin %q: StatusCode = %d; want %d", tt.origin, code, tt.code)
+		}
+	}
+
+	// A CheckOrigin replaces the default.
+	addr = serve(&http.Upgrader{CheckOrigin: func(r *http.Request) bool {
+		return r.Header.Get("Origin") == "http://app.example"
+	}})
+	if code := handshake(addr, "http://app.example"); code != http.StatusSwitchingProtocols {
+		t.Errorf("allowed Origin: StatusCode = %d; want %d", code, http.StatusSwitchingProtocols)
+	}
+	if code := handshake(addr, "http://"+addr); code != http.StatusForbidden {
+		t.Errorf("same Origin rejected by CheckOrigin: StatusCode = %d; want %d", code, http.StatusForbidden)
+	}
+}
@@ -1,6 +1,10 @@
 package http
 
-import "time"
+import (
+	"time"
+
+	"github.com/curol/network/http/internal/timeformat"
+)
 
 // TimeFormat is the time format to use when generating times in HTTP
 // headers. It is like [time.RFC1123] but hard-codes GMT as the time
@@ -10,6 +14,19 @@ import "time"
 // For parsing this time format, see [ParseTime].
 const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
 
+// ParseTime parses a time header (such as the Date: header),
+// trying each of the three formats allowed by HTTP/1.1:
+// [TimeFormat], [time.RFC850], and [time.ANSIC].
+func ParseTime(text string) (t time.Time, err error) {
+	return timeformat.Parse(text)
+}
+
+// FormatTime returns t, converted to UTC, in [TimeFormat], the preferred
+// format of the dates in HTTP headers.
+func FormatTime(t time.Time) string {
+	return string(appendTime(nil, t))
+}
+
 // appendTime is a non-allocating version of []byte(t.UTC().Format(TimeFormat))
 func appendTime(b []byte, t time.Time) []byte {
 	const days = "SunMonTueWedThuFriSat"
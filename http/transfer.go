@@ -0,0 +1,306 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/curol/network/http/internal/ascii"
+)
+
+// chunkedBody is the body of a request sent with "Transfer-Encoding: chunked".
+// Once the terminating chunk has been read, it reads the trailer that follows
+// into trailer.
+type chunkedBody struct {
+	src     io.Reader     // chunked reader
+	r       *bufio.Reader // underlying reader, for reading the trailer
+	trailer Header        // announced trailer keys to populate, if any
+	err     error         // sticky error once the body is exhausted
+}
+
+func (b *chunkedBody) Read(p []byte) (n int, err error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err = b.src.Read(p)
+	if err == io.EOF {
+		if terr := b.readTrailer(); terr != nil {
+			err = terr
+		}
+	}
+	if err != nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *chunkedBody) Close() error {
+	return nil
+}
+
+// fixedLengthBody is the body of a request framed by Content-Length. It reads
+// at most n bytes from r, the reader the head was read from, so reading the
+// body stops at the end of the request rather than running into the next one
+// pipelined on the connection.
+type fixedLengthBody struct {
+	r *bufio.Reader
+	n int64 // bytes remaining
+}
+
+func (b *fixedLengthBody) Read(p []byte) (n int, err error) {
+	if b.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err = b.r.Read(p)
+	b.n -= int64(n)
+	if err == io.EOF && b.n > 0 {
+		// The connection ended before the Content-Length was read.
+		err = io.ErrUnexpectedEOF
+	} else if err == nil && b.n == 0 {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (b *fixedLengthBody) Close() error {
+	return nil
+}
+
+var (
+	nopCloserType         = reflect.TypeOf(io.NopCloser(nil))
+	nopCloserWriterToType = reflect.TypeOf(io.NopCloser(struct {
+		io.Reader
+		io.WriterTo
+	}{}))
+)
+
+// unwrapNopCloser returns the underlying reader and true if r is a NopCloser
+// else it returns false.
+func unwrapNopCloser(r io.Reader) (underlyingReader io.Reader, isNopCloser bool) {
+	switch reflect.TypeOf(r) {
+	case nopCloserType, nopCloserWriterToType:
+		return reflect.ValueOf(r).Field(0).Interface().(io.Reader), true
+	default:
+		return nil, false
+	}
+}
+
+// knownBodyLen returns the number of bytes left in body and true if body is,
+// or wraps with [io.NopCloser], a *bytes.Buffer, *bytes.Reader, or
+// *strings.Reader, the readers whose length is known without reading them.
+func knownBodyLen(body io.Reader) (int64, bool) {
+	if r, ok := unwrapNopCloser(body); ok {
+		body = r
+	}
+	switch v := body.(type) {
+	case *bytes.Buffer:
+		return int64(v.Len()), true
+	case *bytes.Reader:
+		return int64(v.Len()), true
+	case *strings.Reader:
+		return int64(v.Len()), true
+	}
+	return 0, false
+}
+
+// countingBody is a body that adds the number of bytes read from it to n.
+type countingBody struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	*b.n += int64(n)
+	return n, err
+}
+
+// countingWriter is a writer that counts the number of bytes written to w in n.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readTrailer reads the trailer lines following the terminating chunk.
+// Only keys announced in the "Trailer" header are kept; the others are
+// dropped, so a trailer can't smuggle fields the request never announced.
+func (b *chunkedBody) readTrailer() error {
+	h, _, err := readHeader(b.r)
+	if err != nil {
+		return err
+	}
+	for k, vv := range h {
+		if _, ok := b.trailer[k]; ok {
+			b.trailer[k] = vv
+		}
+	}
+	return nil
+}
+
+// announcedTrailer returns a Header with a nil entry for each key announced
+// in the "Trailer" header of `h`, or nil if no trailer is announced.
+//
+// Fields that frame or route the message can't be sent in a trailer
+// (RFC 7230, section 4.1.2), since a recipient that merges the trailer into
+// the header would apply them too late, so announcing one is an error.
+func announcedTrailer(h Header) (Header, error) {
+	var trailer Header
+	for _, v := range h.Values("Trailer") {
+		for _, key := range strings.Split(v, ",") {
+			key = textproto.CanonicalMIMEHeaderKey(textproto.TrimString(key))
+			switch key {
+			case "":
+				continue
+			case "Transfer-Encoding", "Trailer", "Content-Length", "Host":
+				return nil, fmt.Errorf("%w: %q not allowed in trailer", ErrInvalidHeaderLine, key)
+			}
+			if trailer == nil {
+				trailer = make(Header)
+			}
+			trailer[key] = nil
+		}
+	}
+	return trailer, nil
+}
+
+// decompressBody replaces the body of resp by a reader decompressing it if its
+// Content-Encoding is gzip or deflate.
+func decompressBody(resp *Response) {
+	enc, _ := ascii.ToLower(textproto.TrimString(resp.Header.Get("Content-Encoding")))
+	if enc != "gzip" && enc != "deflate" {
+		return
+	}
+	resp.Body = &decompressReader{body: resp.Body, encoding: enc}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decompressReader decompresses a body with the Content-Encoding encoding.
+// The decompressor is created on the first Read, since creating it reads
+// the stream header from the body.
+type decompressReader struct {
+	body     io.ReadCloser
+	encoding string    // "gzip" or "deflate"
+	zr       io.Reader // decompressor of body
+	zerr     error     // error creating zr
+}
+
+func (d *decompressReader) Read(p []byte) (n int, err error) {
+	if d.zr == nil && d.zerr == nil {
+		d.zr, d.zerr = newDecompressor(d.body, d.encoding)
+	}
+	if d.zerr != nil {
+		return 0, d.zerr
+	}
+	return d.zr.Read(p)
+}
+
+func (d *decompressReader) Close() error {
+	return d.body.Close()
+}
+
+// newDecompressor returns a reader decompressing r with the Content-Encoding encoding.
+//
+// The "deflate" coding is a zlib stream (RFC 9110, section 8.4.1.2), but some
+// servers send a raw deflate stream, which is accepted too.
+func newDecompressor(r io.Reader, encoding string) (io.Reader, error) {
+	if encoding == "gzip" {
+		return gzip.NewReader(r)
+	}
+	br := bufio.NewReader(r)
+	if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// aLongTimeAgo is a non-zero time, far in the past, used for
+// immediate cancellation of network operations.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// contextBody is the body of a request read from a connection.
+// When ctx is done, in-flight reads are interrupted by setting a read deadline
+// in the past on the connection, and reads return the context's error.
+type contextBody struct {
+	io.ReadCloser
+	ctx   context.Context
+	stop  func() bool // stops watching ctx; reports false if ctx was already done
+	onEOF func()      // called once the body was read to its end, if not nil
+}
+
+func newContextBody(ctx context.Context, conn net.Conn, body io.ReadCloser) *contextBody {
+	return &contextBody{
+		ReadCloser: body,
+		ctx:        ctx,
+		stop: context.AfterFunc(ctx, func() {
+			conn.SetReadDeadline(aLongTimeAgo)
+		}),
+	}
+}
+
+func (b *contextBody) Read(p []byte) (n int, err error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err = b.ReadCloser.Read(p)
+	if err != nil && b.ctx.Err() != nil {
+		err = b.ctx.Err()
+	}
+	if err == io.EOF && b.onEOF != nil {
+		b.onEOF()
+		b.onEOF = nil
+	}
+	return n, err
+}
+
+// expectContinueReader is the body of a request sent with "Expect: 100-continue".
+// On the first read, it replies "100 Continue" on the connection so the client
+// sends the body, unless the handler has already written a response header
+// (e.g., 417 Expectation Failed) to reject the request.
+type expectContinueReader struct {
+	readCloser    io.ReadCloser
+	w             *bufio.Writer // connection writer, for the interim response
+	res           *Response     // response of the handler
+	wroteContinue bool
+	closed        bool
+}
+
+func (ecr *expectContinueReader) Read(p []byte) (n int, err error) {
+	if ecr.closed {
+		return 0, ErrBodyReadAfterClose
+	}
+	if !ecr.wroteContinue && !ecr.res.wroteHeader {
+		ecr.wroteContinue = true
+		ecr.w.WriteString("HTTP/1.1 100 Continue\r\n\r\n")
+		if err := ecr.w.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return ecr.readCloser.Read(p)
+}
+
+func (ecr *expectContinueReader) Close() error {
+	ecr.closed = true
+	return ecr.readCloser.Close()
+}
@@ -2,13 +2,14 @@ package http
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net"
-	"net/http"
+	"net/textproto"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -55,18 +56,44 @@ func removeEmptyPort(host string) string {
 func hasPort(s string) bool { return strings.LastIndex(s, ":") > strings.LastIndex(s, "]") }
 
 func getContentLength(header Header) int64 {
-	if header == nil {
+	n, err := parseContentLength(header)
+	if err != nil {
 		return 0
 	}
-	cl := header.Get("Content-Length")
+	return n
+}
+
+// parseContentLength returns the length given by the Content-Length header,
+// or 0 if there is none.
+//
+// Since a message framed by Content-Length values that disagree could be read
+// differently by a proxy and the server behind it (request smuggling), an error
+// wrapping [ErrInvalidContentLength] is returned if there are multiple differing
+// values or the value isn't a non-negative decimal number. Multiple equal values
+// are accepted, as permitted by RFC 7230, section 3.3.2.
+func parseContentLength(header Header) (int64, error) {
+	var cl string
+	for _, v := range header["Content-Length"] {
+		for _, f := range strings.Split(v, ",") {
+			f = textproto.TrimString(f)
+			if cl != "" && f != cl {
+				return 0, fmt.Errorf("%w: multiple values %q", ErrInvalidContentLength, header["Content-Length"])
+			}
+			cl = f
+		}
+	}
 	if cl == "" {
-		return 0
+		if len(header["Content-Length"]) > 0 {
+			return 0, fmt.Errorf("%w %q", ErrInvalidContentLength, "")
+		}
+		return 0, nil
 	}
-	v, err := strconv.Atoi(cl)
+	// ParseUint rejects signs, so "-1" and "+1" are invalid.
+	n, err := strconv.ParseUint(cl, 10, 63)
 	if err != nil {
-		return 0
+		return 0, fmt.Errorf("%w %q", ErrInvalidContentLength, cl)
 	}
-	return int64(v)
+	return int64(n), nil
 }
 
 func addSchemeIfMissing(rawurl string) (string, error) {
@@ -214,23 +241,27 @@ func ParseHTTPVersion(vers string) (major, minor int, ok bool) {
 }
 
 // parseRequestLine parses "GET /foo HTTP/1.1" into its three parts.
+//
+// Per RFC 9112, section 3, the parts must be separated by exactly one space (SP),
+// so lines with repeated spaces, tabs, trailing data, or control characters are rejected.
+// The line may end with "\r\n" or "\n".
 func parseRequestLine(line string) (method, requestURI, prot string, ok bool) {
-	// Alternative
-	// method, rest, ok1 := strings.Cut(line, " ")
-	// requestURI, proto, ok2 := strings.Cut(rest, " ")
-	// if !ok1 || !ok2 {
-	// 	return "", "", "", false
-	// }
-	// return method, requestURI, proto, true
-	// Original
-	parts := strings.SplitN(line, " ", 3) // parse first line by splitting into method, path, and protocol
-	if len(parts) != 3 {
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	for i := 0; i < len(line); i++ {
+		if isCTL(line[i]) {
+			return "", "", "", false
+		}
+	}
+	method, rest, ok1 := strings.Cut(line, " ")
+	requestURI, prot, ok2 := strings.Cut(rest, " ")
+	if !ok1 || !ok2 {
+		return "", "", "", false
+	}
+	if !validMethod(method) || requestURI == "" || prot == "" || strings.Contains(prot, " ") {
 		return "", "", "", false
 	}
-	method = strings.ToUpper(strings.TrimSpace(parts[0]))
-	requestURI = strings.TrimSpace(parts[1])
-	prot = strings.TrimSpace(parts[2])
-	return method, requestURI, prot, true
+	return strings.ToUpper(method), requestURI, prot, true
 }
 
 func getRequestLine(method string, address string, protocol string) string {
@@ -254,8 +285,8 @@ func requestFromMap(params map[string]string) (*Request, error) {
 	}
 
 	r.Close = true
-	r.Trailer = http.Header{}
-	r.Header = http.Header{}
+	r.Trailer = Header{}
+	r.Header = Header{}
 
 	r.Host = params["HTTP_HOST"]
 
@@ -863,3 +894,30 @@ func parsePostForm(r *Request) (vs url.Values, err error) {
 	}
 	return
 }
+
+// diffBody returns a "Body: <a> != <b>" line if the bodies *a and *b differ.
+//
+// The bodies are read to be compared, so they're replaced with readers of the
+// bytes read. A nil body is the same as an empty one.
+func diffBody(a, b *io.ReadCloser) []string {
+	ab, aerr := readAndRestoreBody(a)
+	bb, berr := readAndRestoreBody(b)
+	if aerr != nil || berr != nil {
+		return []string{fmt.Sprintf("Body: read error %v != %v", aerr, berr)}
+	}
+	if !bytes.Equal(ab, bb) {
+		return []string{fmt.Sprintf("Body: %q != %q", ab, bb)}
+	}
+	return nil
+}
+
+// readAndRestoreBody reads all of *body and replaces it with a reader of the bytes read.
+func readAndRestoreBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(*body)
+	(*body).Close()
+	*body = io.NopCloser(bytes.NewReader(b))
+	return b, err
+}
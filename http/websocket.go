@@ -0,0 +1,117 @@
+package http
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net"
+
+	"github.com/curol/network/http/internal/ascii"
+	url "github.com/curol/network/url"
+)
+
+// websocketGUID is appended to the Sec-WebSocket-Key of a handshake to compute
+// the Sec-WebSocket-Accept of the response, as specified by RFC 6455, section 1.3.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Upgrader upgrades an HTTP/1.1 connection to the WebSocket protocol (RFC 6455).
+//
+// Only the opening handshake is handled; the caller reads and writes
+// WebSocket frames on the returned connection.
+type Upgrader struct {
+	// CheckOrigin returns true if the request's Origin header is acceptable.
+	// If CheckOrigin is nil, a request is accepted only if it has no Origin
+	// header, as from non-browser clients, or if the host of its Origin equals
+	// the request's Host, so pages of other sites can't open connections with
+	// the user's cookies.
+	CheckOrigin func(r *Request) bool
+}
+
+// Upgrade validates the WebSocket handshake of r, hijacks the connection, and
+// replies "101 Switching Protocols" with the Sec-WebSocket-Accept computed from
+// the request's Sec-WebSocket-Key.
+//
+// The returned reader may hold frames the client sent right after the handshake,
+// so frames should be read through it rather than the connection. The caller
+// is responsible for closing the connection.
+//
+// If the handshake is invalid, Upgrade replies to the request with an HTTP error
+// and returns an error.
+func (u *Upgrader) Upgrade(w ResponseWriter, r *Request) (net.Conn, *bufio.ReadWriter, error) {
+	if r.Method != "GET" {
+		return nil, nil, u.fail(w, StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !hasToken(r.Header.Get("Connection"), "upgrade") {
+		return nil, nil, u.fail(w, StatusBadRequest, "'Connection' header does not contain 'Upgrade'")
+	}
+	if !hasToken(r.Header.Get("Upgrade"), "websocket") {
+		return nil, nil, u.fail(w, StatusBadRequest, "'Upgrade' header does not contain 'websocket'")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, nil, u.fail(w, StatusUpgradeRequired, "unsupported 'Sec-WebSocket-Version'")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !validWebSocketKey(key) {
+		return nil, nil, u.fail(w, StatusBadRequest, "invalid 'Sec-WebSocket-Key'")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = checkSameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, nil, u.fail(w, StatusForbidden, "origin not allowed")
+	}
+	h, ok := w.(Hijacker)
+	if !ok {
+		return nil, nil, u.fail(w, StatusInternalServerError, "response does not implement Hijacker")
+	}
+
+	conn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	brw.WriteString("Upgrade: websocket\r\n")
+	brw.WriteString("Connection: Upgrade\r\n")
+	brw.WriteString("Sec-WebSocket-Accept: " + computeAcceptKey(key) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, brw, nil
+}
+
+// fail replies to the request with the HTTP error code and returns the reason as an error.
+func (u *Upgrader) fail(w ResponseWriter, code int, reason string) error {
+	Error(w, StatusText(code), code)
+	return errors.New("http: websocket: " + reason)
+}
+
+// checkSameOrigin reports whether r has no Origin header or one whose host
+// equals r.Host.
+func checkSameOrigin(r *Request) bool {
+	origin := r.Header["Origin"]
+	if len(origin) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin[0])
+	if err != nil {
+		return false
+	}
+	return ascii.EqualFold(u.Host, r.Host)
+}
+
+// validWebSocketKey reports whether key is the base64 encoding of a 16-byte value.
+func validWebSocketKey(key string) bool {
+	b, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(b) == 16
+}
+
+// computeAcceptKey returns the Sec-WebSocket-Accept for the Sec-WebSocket-Key key.
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
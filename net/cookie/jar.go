@@ -0,0 +1,368 @@
+package cookie
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// PublicSuffixList provides the public suffix of a domain. For example:
+//   - the public suffix of "example.com" is "com",
+//   - the public suffix of "foo1.foo2.foo3.co.uk" is "co.uk", and
+//   - the public suffix of "bar.pvt.k12.ma.us" is "pvt.k12.ma.us".
+//
+// Cookies can't be set for a public suffix, so one site can't set cookies
+// sent to every other site under the same suffix.
+type PublicSuffixList interface {
+	// PublicSuffix returns the public suffix of domain.
+	PublicSuffix(domain string) string
+}
+
+// JarOptions are the options of [NewJar].
+type JarOptions struct {
+	// PublicSuffixList is the public suffix list that determines whether
+	// a Domain attribute is allowed. If nil, the list of
+	// golang.org/x/net/publicsuffix is used.
+	PublicSuffixList PublicSuffixList
+}
+
+// Jar stores the cookies set by HTTP responses and returns the ones to send
+// with HTTP requests, as specified by RFC 6265. It is safe for concurrent use.
+//
+// Cookies are stored per registrable domain (eTLD+1), e.g., "example.com" for
+// "www.example.com", and are returned for a URL only if its host, path, and
+// scheme match the cookie's Domain, Path, and Secure attributes.
+type Jar struct {
+	psList PublicSuffixList
+
+	mu      sync.Mutex
+	entries map[string]map[string]entry // eTLD+1 -> entry ID -> entry
+	nextSeq uint64                      // orders entries created at the same time
+}
+
+// entry is a cookie stored in a Jar.
+type entry struct {
+	Name       string
+	Value      string
+	Quoted     bool
+	Domain     string
+	Path       string
+	Secure     bool
+	HttpOnly   bool
+	Persistent bool // the cookie has an expiry time; otherwise it lasts for the session
+	HostOnly   bool // the cookie is sent to its Domain only and not to subdomains
+	Expires    time.Time
+	Creation   time.Time
+	seqNum     uint64
+}
+
+// id returns the identifier of e in its domain: cookies with the same name,
+// domain, and path replace each other.
+func (e *entry) id() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+// publicSuffixList is the default PublicSuffixList of a Jar.
+type publicSuffixList struct{}
+
+func (publicSuffixList) PublicSuffix(domain string) string {
+	ps, _ := publicsuffix.PublicSuffix(domain)
+	return ps
+}
+
+var (
+	errIllegalDomain   = errors.New("cookie: illegal cookie domain attribute")
+	errMalformedDomain = errors.New("cookie: malformed cookie domain attribute")
+	errNoHostname      = errors.New("cookie: no host name available (IP only)")
+)
+
+// NewJar returns an empty Jar.
+func NewJar(opts *JarOptions) *Jar {
+	jar := &Jar{
+		psList:  publicSuffixList{},
+		entries: make(map[string]map[string]entry),
+	}
+	if opts != nil && opts.PublicSuffixList != nil {
+		jar.psList = opts.PublicSuffixList
+	}
+	return jar
+}
+
+// SetCookies stores the cookies received in a response to a request for u.
+// Cookies whose Domain doesn't match u's host or is a public suffix are
+// rejected, and cookies that are expired by their Max-Age or Expires
+// attribute delete the stored cookie they replace.
+func (j *Jar) SetCookies(u *url.URL, cookies []*Cookie) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return
+	}
+	key := j.jarKey(host)
+	defPath := defaultPath(u.Path)
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	submap := j.entries[key]
+	for _, c := range cookies {
+		e, remove, err := j.newEntry(c, now, defPath, host)
+		if err != nil {
+			continue
+		}
+		id := e.id()
+		if remove {
+			delete(submap, id)
+			continue
+		}
+		if submap == nil {
+			submap = make(map[string]entry)
+		}
+		if old, ok := submap[id]; ok {
+			e.Creation = old.Creation
+			e.seqNum = old.seqNum
+		} else {
+			e.Creation = now
+			e.seqNum = j.nextSeq
+			j.nextSeq++
+		}
+		submap[id] = e
+	}
+	if len(submap) == 0 {
+		delete(j.entries, key)
+	} else {
+		j.entries[key] = submap
+	}
+}
+
+// Cookies returns the cookies to send in a request for u, with the longest
+// paths first. Only their Name, Value, and Quoted fields are set.
+// Expired cookies are removed from the jar.
+func (j *Jar) Cookies(u *url.URL) []*Cookie {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return nil
+	}
+	key := j.jarKey(host)
+	https := u.Scheme == "https"
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	submap := j.entries[key]
+	var selected []entry
+	for id, e := range submap {
+		if e.Persistent && !e.Expires.After(now) {
+			delete(submap, id)
+			continue
+		}
+		if e.shouldSend(https, host, path) {
+			selected = append(selected, e)
+		}
+	}
+	if len(submap) == 0 {
+		delete(j.entries, key)
+	}
+
+	// RFC 6265, section 5.4, point 2: longer paths first, then earlier creation.
+	sort.Slice(selected, func(i, k int) bool {
+		s := selected
+		if len(s[i].Path) != len(s[k].Path) {
+			return len(s[i].Path) > len(s[k].Path)
+		}
+		if ret := s[i].Creation.Compare(s[k].Creation); ret != 0 {
+			return ret < 0
+		}
+		return s[i].seqNum < s[k].seqNum
+	})
+	cookies := make([]*Cookie, 0, len(selected))
+	for _, e := range selected {
+		cookies = append(cookies, &Cookie{Name: e.Name, Value: e.Value, Quoted: e.Quoted})
+	}
+	return cookies
+}
+
+// newEntry creates the entry of c received from host, whose request path
+// defaults the cookie's path to defPath. If remove is true, c deletes the
+// stored cookie with the same ID instead.
+func (j *Jar) newEntry(c *Cookie, now time.Time, defPath, host string) (e entry, remove bool, err error) {
+	e.Name = c.Name
+	e.Value = c.Value
+	e.Quoted = c.Quoted
+	if c.Path == "" || c.Path[0] != '/' {
+		e.Path = defPath
+	} else {
+		e.Path = c.Path
+	}
+	e.Domain, e.HostOnly, err = j.domainAndType(host, c.Domain)
+	if err != nil {
+		return e, false, err
+	}
+
+	// MaxAge takes precedence over Expires.
+	if c.MaxAge < 0 {
+		return e, true, nil
+	} else if c.MaxAge > 0 {
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		e.Persistent = true
+	} else if !c.Expires.IsZero() {
+		if !c.Expires.After(now) {
+			return e, true, nil
+		}
+		e.Expires = c.Expires
+		e.Persistent = true
+	}
+
+	e.Secure = c.Secure
+	e.HttpOnly = c.HttpOnly
+	return e, false, nil
+}
+
+// domainAndType returns the domain of a cookie with the Domain attribute
+// domain received from host, and whether it is host-only.
+func (j *Jar) domainAndType(host, domain string) (string, bool, error) {
+	if domain == "" {
+		// No domain attribute: the cookie is sent to host only.
+		return host, true, nil
+	}
+	if net.ParseIP(host) != nil {
+		// RFC 6265 is unclear here, but browsers only accept a domain
+		// attribute equal to the IP address.
+		if host != domain {
+			return "", false, errNoHostname
+		}
+		return host, true, nil
+	}
+
+	// A leading dot is ignored (RFC 6265, section 5.2.3).
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if domain == "" || domain[len(domain)-1] == '.' {
+		return "", false, errMalformedDomain
+	}
+
+	// A cookie for a public suffix would be sent to every site under it.
+	// It is only allowed as a host-only cookie of the suffix itself
+	// (RFC 6265, section 5.3, step 5).
+	if ps := j.psList.PublicSuffix(domain); ps != "" && !hasDotSuffix(domain, ps) {
+		if host == domain {
+			return host, true, nil
+		}
+		return "", false, errIllegalDomain
+	}
+
+	// The domain must domain-match host: www.example.com can set cookies
+	// for example.com, but not for other.com or www.other.example.com.
+	if host != domain && !hasDotSuffix(host, domain) {
+		return "", false, errIllegalDomain
+	}
+	return domain, false, nil
+}
+
+// shouldSend reports whether e should be sent in a request for path on host,
+// over https if https is set.
+func (e *entry) shouldSend(https bool, host, path string) bool {
+	return e.domainMatch(host) && e.pathMatch(path) && (https || !e.Secure)
+}
+
+// domainMatch implements "domain-match" of RFC 6265, section 5.1.3.
+func (e *entry) domainMatch(host string) bool {
+	if e.Domain == host {
+		return true
+	}
+	return !e.HostOnly && hasDotSuffix(host, e.Domain)
+}
+
+// pathMatch implements "path-match" of RFC 6265, section 5.1.4.
+func (e *entry) pathMatch(requestPath string) bool {
+	if requestPath == e.Path {
+		return true
+	}
+	if strings.HasPrefix(requestPath, e.Path) {
+		if e.Path[len(e.Path)-1] == '/' {
+			return true // "/any/" matches "/any/path"
+		} else if requestPath[len(e.Path)] == '/' {
+			return true // "/any" matches "/any/path"
+		}
+	}
+	return false
+}
+
+// jarKey returns the key of the entries of host: its eTLD+1, or host itself
+// if it is an IP address or a public suffix.
+func (j *Jar) jarKey(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	ps := j.psList.PublicSuffix(host)
+	if ps == "" || ps == host || !hasDotSuffix(host, ps) {
+		return host
+	}
+	prevDot := strings.LastIndex(host[:len(host)-len(ps)-1], ".")
+	return host[prevDot+1:]
+}
+
+// canonicalHost strips the port from host, if any, and the brackets of an
+// IPv6 address, and lowercases it.
+func canonicalHost(host string) (string, error) {
+	if hasPort(host) {
+		var err error
+		host, _, err = net.SplitHostPort(host)
+		if err != nil {
+			return "", err
+		}
+	} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	// Strip trailing dot from fully qualified domain names.
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return "", errNoHostname
+	}
+	return strings.ToLower(host), nil
+}
+
+// hasPort reports whether host contains a port number. host may be a host
+// name, an IPv4 or an IPv6 address.
+func hasPort(host string) bool {
+	colons := strings.Count(host, ":")
+	if colons == 0 {
+		return false
+	}
+	if colons == 1 {
+		return true
+	}
+	return host[0] == '[' && strings.Contains(host, "]:")
+}
+
+// defaultPath returns the directory part of a URL's path according to
+// RFC 6265, section 5.1.4.
+func defaultPath(path string) string {
+	if len(path) == 0 || path[0] != '/' {
+		return "/" // Path is empty or malformed.
+	}
+	i := strings.LastIndex(path, "/") // Path starts with "/", so i != -1.
+	if i == 0 {
+		return "/" // Path has the form "/abc".
+	}
+	return path[:i] // Path is either of form "/abc/xyz" or "/abc/xyz/".
+}
+
+// hasDotSuffix reports whether s ends in "."+suffix.
+func hasDotSuffix(s, suffix string) bool {
+	return len(s) > len(suffix) && s[len(s)-len(suffix)-1] == '.' && s[len(s)-len(suffix):] == suffix
+}
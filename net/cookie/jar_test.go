@@ -0,0 +1,134 @@
+package cookie
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// jarCookies returns the "name=value" of the cookies of jar for rawURL.
+func jarCookies(t *testing.T, jar *Jar, rawURL string) []string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s []string
+	for _, c := range jar.Cookies(u) {
+		s = append(s, c.Name+"="+c.Value)
+	}
+	return s
+}
+
+func setJarCookies(t *testing.T, jar *Jar, rawURL string, cookies ...*Cookie) {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar.SetCookies(u, cookies)
+}
+
+func TestJarDomain(t *testing.T) {
+	jar := NewJar(nil)
+	setJarCookies(t, jar, "http://www.example.com/",
+		&Cookie{Name: "domain", Value: "1", Domain: "example.com"},
+		&Cookie{Name: "host", Value: "2"},
+		&Cookie{Name: "suffix", Value: "3", Domain: "com"},
+		&Cookie{Name: "other", Value: "4", Domain: "other.com"},
+	)
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"http://www.example.com/", []string{"domain=1", "host=2"}},
+		{"http://example.com/", []string{"domain=1"}},
+		{"http://sub.example.com/", []string{"domain=1"}},
+		{"http://www.example.com:8080/", []string{"domain=1", "host=2"}},
+		{"http://other.com/", nil},
+		{"http://site.com/", nil},
+	}
+	for _, tt := range tests {
+		if got := jarCookies(t, jar, tt.url); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Cookies(%s) = %q; want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestJarPathAndSecure(t *testing.T) {
+	jar := NewJar(nil)
+	setJarCookies(t, jar, "https://example.com/docs/index.html",
+		&Cookie{Name: "default", Value: "1"}, // path defaults to /docs
+		&Cookie{Name: "root", Value: "2", Path: "/"},
+		&Cookie{Name: "secure", Value: "3", Path: "/docs/api", Secure: true},
+	)
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://example.com/docs/api/v1", []string{"secure=3", "default=1", "root=2"}},
+		{"http://example.com/docs/api/v1", []string{"default=1", "root=2"}},
+		{"https://example.com/docsearch", []string{"root=2"}},
+		{"https://example.com/", []string{"root=2"}},
+	}
+	for _, tt := range tests {
+		if got := jarCookies(t, jar, tt.url); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Cookies(%s) = %q; want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestJarExpiry(t *testing.T) {
+	jar := NewJar(nil)
+	setJarCookies(t, jar, "http://example.com/",
+		&Cookie{Name: "a", Value: "1", MaxAge: 60},
+		&Cookie{Name: "b", Value: "2", Expires: time.Now().Add(time.Hour)},
+		&Cookie{Name: "c", Value: "3"},
+		&Cookie{Name: "expired", Value: "4", Expires: time.Now().Add(-time.Hour)},
+	)
+	if got, want := len(jarCookies(t, jar, "http://example.com/")), 3; got != want {
+		t.Fatalf("got %d cookies; want %d", got, want)
+	}
+
+	// A negative Max-Age or a past Expires deletes the cookie.
+	setJarCookies(t, jar, "http://example.com/",
+		&Cookie{Name: "a", Value: "x", MaxAge: -1},
+		&Cookie{Name: "b", Value: "x", Expires: time.Unix(1, 0)},
+	)
+	if got, want := jarCookies(t, jar, "http://example.com/"), []string{"c=3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Cookies after deletion = %q; want %q", got, want)
+	}
+}
+
+func TestCanonicalHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"www.Example.COM", "www.example.com"},
+		{"www.example.com.", "www.example.com"},
+		{"www.example.com:8080", "www.example.com"},
+		{"192.168.0.10", "192.168.0.10"},
+		{"192.168.0.10:8080", "192.168.0.10"},
+		{"[::1]", "::1"},
+		{"[::1]:8080", "::1"},
+		{"[2001:DB8::1]", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		if got, err := canonicalHost(tt.host); err != nil || got != tt.want {
+			t.Errorf("canonicalHost(%q) = %q, %v; want %q", tt.host, got, err, tt.want)
+		}
+	}
+
+	// A host-only cookie of an IPv6 host is sent whether or not the URL has a port.
+	jar := NewJar(nil)
+	setJarCookies(t, jar, "http://[::1]/", &Cookie{Name: "host", Value: "1"})
+	for _, u := range []string{"http://[::1]/", "http://[::1]:8080/"} {
+		if got := jarCookies(t, jar, u); !reflect.DeepEqual(got, []string{"host=1"}) {
+			t.Errorf("Cookies(%s) = %q; want %q", u, got, []string{"host=1"})
+		}
+	}
+}